@@ -0,0 +1,137 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间源，业务代码通过它获取当前时间和创建定时器，测试中可以替换为 Fake 手动推进时间。
+type Clock interface {
+	Now() time.Time                   // 当前时间
+	NewTicker(d time.Duration) Ticker // 创建周期为 d 的定时器
+}
+
+// Ticker 周期性定时器，与 time.Ticker 相同，C 返回接收时间的 channel。
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real 使用系统时间的 Clock。
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Fake 手动推进的 Clock，只有调用 Advance 时时间才会前进。
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFake 创建一个以 now 为当前时间的 Fake。
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker 创建周期为 d 的定时器，d 必须为正数。
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{
+		clock:  f,
+		c:      make(chan time.Time),
+		done:   make(chan struct{}),
+		period: d,
+		next:   f.now.Add(d),
+	}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance 将时间推进 d，按时间顺序触发期间到期的每一次定时。
+// 与 time.Ticker 不同，每次定时都会阻塞到被接收或定时器停止为止，不会丢弃，
+// 因此 Advance 返回时，期间的每次定时都已经被接收。
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	for {
+		var earliest *fakeTicker
+		for _, t := range f.tickers {
+			if !t.next.After(target) && (earliest == nil || t.next.Before(earliest.next)) {
+				earliest = t
+			}
+		}
+		if earliest == nil {
+			break
+		}
+		now := earliest.next
+		f.now = now
+		earliest.next = now.Add(earliest.period)
+		f.mu.Unlock()
+		select {
+		case earliest.c <- now:
+		case <-earliest.done:
+		}
+		f.mu.Lock()
+	}
+	f.now = target
+	f.mu.Unlock()
+}
+
+// removeTicker 移除已经停止的定时器。
+func (f *Fake) removeTicker(t *fakeTicker) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.tickers {
+		if f.tickers[i] == t {
+			f.tickers = append(f.tickers[:i], f.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	c      chan time.Time
+	done   chan struct{}
+	once   sync.Once
+	period time.Duration
+	next   time.Time // 下一次定时的时间
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.done)
+		t.clock.removeTicker(t)
+	})
+}
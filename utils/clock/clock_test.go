@@ -0,0 +1,48 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	ast := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	ast.Equal(start, fake.Now())
+
+	fast := fake.NewTicker(2 * time.Second)
+	slow := fake.NewTicker(3 * time.Second)
+	got := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			select {
+			case tm := <-fast.C():
+				got <- "fast@" + tm.Sub(start).String()
+			case tm := <-slow.C():
+				got <- "slow@" + tm.Sub(start).String()
+			}
+		}
+	}()
+	// 6s 时两个定时器同时到期，先创建的先触发
+	fake.Advance(7 * time.Second)
+	<-done
+	close(got)
+	var result []string
+	for s := range got {
+		result = append(result, s)
+	}
+	ast.Equal([]string{"fast@2s", "slow@3s", "fast@4s", "fast@6s", "slow@6s"}, result)
+	ast.Equal(start.Add(7*time.Second), fake.Now())
+
+	// 停止后的定时器不再阻塞 Advance
+	fast.Stop()
+	slow.Stop()
+	fast.Stop()
+	fake.Advance(time.Hour)
+	ast.Equal(start.Add(time.Hour+7*time.Second), fake.Now())
+}
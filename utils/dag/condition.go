@@ -0,0 +1,385 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TypedCondition 条件边上的布尔条件表达式，根据已完成的节点集合求值
+type TypedCondition[K comparable] interface {
+	// Eval 根据已完成的节点集合求值
+	Eval(completed map[K]bool) bool
+	// Nodes 返回表达式引用的所有节点且不重复，K 为字符串或数值类型时按 ID 大小排列，否则按出现的顺序排列
+	Nodes() []K
+	// String 返回表达式的文本形式，节点 ID 为字符串时可以被 ParseCondition 重新解析
+	String() string
+}
+
+// Condition 节点 ID 为字符串的条件表达式，DAG 使用
+type Condition = TypedCondition[string]
+
+// Ref 节点已完成
+func Ref[K comparable](id K) TypedCondition[K] {
+	return refCond[K]{id: id}
+}
+
+// All 所有条件都满足(AND)，没有条件时恒为真
+func All[K comparable](items ...TypedCondition[K]) TypedCondition[K] {
+	return allCond[K](items)
+}
+
+// Any 任意一个条件满足(OR)，没有条件时恒为假
+func Any[K comparable](items ...TypedCondition[K]) TypedCondition[K] {
+	return anyCond[K](items)
+}
+
+// Not 条件不满足(NOT)
+func Not[K comparable](item TypedCondition[K]) TypedCondition[K] {
+	return notCond[K]{item: item}
+}
+
+// AtLeast 至少有 k 个条件满足，例如"完成三个章节中的任意两个"
+func AtLeast[K comparable](k int, items ...TypedCondition[K]) TypedCondition[K] {
+	return atLeastCond[K]{k: k, items: items}
+}
+
+// AllOf 所有节点都已完成，与 AddConditionalEdge 的条件列表语义相同
+func AllOf[K comparable](ids ...K) TypedCondition[K] {
+	items := make([]TypedCondition[K], len(ids))
+	for i, id := range ids {
+		items[i] = Ref(id)
+	}
+	return All(items...)
+}
+
+type refCond[K comparable] struct {
+	id K
+}
+
+func (c refCond[K]) Eval(completed map[K]bool) bool { return completed[c.id] }
+func (c refCond[K]) Nodes() []K                     { return []K{c.id} }
+func (c refCond[K]) String() string {
+	if id, ok := any(c.id).(string); ok {
+		return quoteIdent(id)
+	}
+	return fmt.Sprint(c.id)
+}
+
+// quoteIdent 节点 ID 包含语法字符、空白或为空时加上双引号，保证可以被 ParseCondition 重新解析
+func quoteIdent(id string) string {
+	if id == "" || strings.ContainsFunc(id, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`()&|!,"\`, r)
+	}) {
+		return strconv.Quote(id)
+	}
+	return id
+}
+
+type allCond[K comparable] []TypedCondition[K]
+
+func (c allCond[K]) Eval(completed map[K]bool) bool {
+	for _, item := range c {
+		if !item.Eval(completed) {
+			return false
+		}
+	}
+	return true
+}
+func (c allCond[K]) Nodes() []K     { return collectNodes(c) }
+func (c allCond[K]) String() string { return joinConditions(c, " & ") }
+
+type anyCond[K comparable] []TypedCondition[K]
+
+func (c anyCond[K]) Eval(completed map[K]bool) bool {
+	for _, item := range c {
+		if item.Eval(completed) {
+			return true
+		}
+	}
+	return false
+}
+func (c anyCond[K]) Nodes() []K     { return collectNodes(c) }
+func (c anyCond[K]) String() string { return joinConditions(c, " | ") }
+
+type notCond[K comparable] struct {
+	item TypedCondition[K]
+}
+
+func (c notCond[K]) Eval(completed map[K]bool) bool { return !c.item.Eval(completed) }
+func (c notCond[K]) Nodes() []K                     { return c.item.Nodes() }
+func (c notCond[K]) String() string {
+	if _, ok := c.item.(refCond[K]); ok {
+		return "!" + c.item.String()
+	}
+	return "!(" + c.item.String() + ")"
+}
+
+type atLeastCond[K comparable] struct {
+	k     int
+	items []TypedCondition[K]
+}
+
+func (c atLeastCond[K]) Eval(completed map[K]bool) bool {
+	count := 0
+	for _, item := range c.items {
+		if item.Eval(completed) {
+			count++
+			if count >= c.k {
+				return true
+			}
+		}
+	}
+	return count >= c.k
+}
+func (c atLeastCond[K]) Nodes() []K { return collectNodes(c.items) }
+func (c atLeastCond[K]) String() string {
+	parts := make([]string, 0, len(c.items)+1)
+	parts = append(parts, strconv.Itoa(c.k))
+	for _, item := range c.items {
+		parts = append(parts, item.String())
+	}
+	return "atleast(" + strings.Join(parts, ", ") + ")"
+}
+
+// collectNodes 汇总多个表达式引用的节点
+func collectNodes[K comparable](items []TypedCondition[K]) []K {
+	seen := make(map[K]struct{})
+	result := []K{}
+	for _, item := range items {
+		for _, id := range item.Nodes() {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				result = append(result, id)
+			}
+		}
+	}
+	if less := orderedLess[K](); less != nil {
+		sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+	}
+	return result
+}
+
+// joinConditions 以运算符连接多个表达式，包含多个子项的 AND/OR 子表达式加括号
+func joinConditions[K comparable](items []TypedCondition[K], sep string) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = item.String()
+		switch v := item.(type) {
+		case allCond[K]:
+			if len(v) > 1 {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		case anyCond[K]:
+			if len(v) > 1 {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// ParseCondition 解析文本形式的条件表达式，语法如下(优先级从低到高)：
+//
+//	A | B               任意一个满足
+//	A & B               全部满足
+//	!A                  不满足
+//	(A | B) & C         括号
+//	atleast(2, A, B, C) 至少满足 k 个，参数可以是任意表达式
+//
+// 节点 ID 可以包含除空白、括号、逗号、引号和 &|! 以外的任意字符，
+// 包含这些字符的节点 ID 需要写成带双引号的形式，例如 "Chapter 1"，转义规则与 Go 的字符串字面量相同
+func ParseCondition(text string) (Condition, error) {
+	p := &conditionParser{src: []rune(text)}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("多余的字符 %q", string(p.src[p.pos]))
+	}
+	return cond, nil
+}
+
+type conditionParser struct {
+	src []rune
+	pos int
+}
+
+func (p *conditionParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("条件表达式第 %d 个字符: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *conditionParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// peek 跳过空白后返回下一个字符
+func (p *conditionParser) peek() (rune, bool) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0, false
+	}
+	return p.src[p.pos], true
+}
+
+// accept 下一个字符为 r 时消费它，兼容 && 与 || 的写法
+func (p *conditionParser) accept(r rune) bool {
+	if c, ok := p.peek(); !ok || c != r {
+		return false
+	}
+	p.pos++
+	if (r == '&' || r == '|') && p.pos < len(p.src) && p.src[p.pos] == r {
+		p.pos++
+	}
+	return true
+}
+
+func (p *conditionParser) expect(r rune) error {
+	if !p.accept(r) {
+		return p.errorf("缺少 %q", string(r))
+	}
+	return nil
+}
+
+func (p *conditionParser) parseOr() (Condition, error) {
+	items, err := p.parseList('|', p.parseAnd)
+	if err != nil || len(items) == 1 {
+		return first(items), err
+	}
+	return Any(items...), nil
+}
+
+func (p *conditionParser) parseAnd() (Condition, error) {
+	items, err := p.parseList('&', p.parseUnary)
+	if err != nil || len(items) == 1 {
+		return first(items), err
+	}
+	return All(items...), nil
+}
+
+// parseList 解析以 sep 分隔的多个子表达式
+func (p *conditionParser) parseList(sep rune, next func() (Condition, error)) ([]Condition, error) {
+	var items []Condition
+	for {
+		item, err := next()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.accept(sep) {
+			return items, nil
+		}
+	}
+}
+
+func first(items []Condition) Condition {
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
+func (p *conditionParser) parseUnary() (Condition, error) {
+	if p.accept('!') {
+		item, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(item), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *conditionParser) parsePrimary() (Condition, error) {
+	if p.accept('(') {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(')')
+	}
+	if c, ok := p.peek(); ok && c == '"' {
+		id, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return Ref(id), nil
+	}
+	id := p.parseIdent()
+	if id == "" {
+		if _, ok := p.peek(); !ok {
+			return nil, p.errorf("表达式不完整")
+		}
+		return nil, p.errorf("无效的字符 %q", string(p.src[p.pos]))
+	}
+	if id == "atleast" {
+		if c, ok := p.peek(); ok && c == '(' {
+			return p.parseAtLeast()
+		}
+	}
+	return Ref(id), nil
+}
+
+// parseAtLeast 解析 atleast(k, expr, ...) 中 "(" 之后的部分
+func (p *conditionParser) parseAtLeast() (Condition, error) {
+	_ = p.expect('(')
+	num := p.parseIdent()
+	k, err := strconv.Atoi(num)
+	if err != nil || k < 0 {
+		return nil, p.errorf("atleast 的第一个参数必须是非负整数")
+	}
+	var items []Condition
+	for p.accept(',') {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err = p.expect(')'); err != nil {
+		return nil, err
+	}
+	return AtLeast(k, items...), nil
+}
+
+// parseQuoted 解析带双引号的节点 ID
+func (p *conditionParser) parseQuoted() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			text := string(p.src[start:p.pos])
+			id, err := strconv.Unquote(text)
+			if err != nil {
+				p.pos = start
+				return "", p.errorf("无效的节点 ID %s", text)
+			}
+			return id, nil
+		}
+	}
+	p.pos = start
+	return "", p.errorf("节点 ID 缺少结束的引号")
+}
+
+// parseIdent 解析节点 ID
+func (p *conditionParser) parseIdent() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if unicode.IsSpace(r) || strings.ContainsRune(`()&|!,"`, r) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"fmt"
+	"math"
+)
+
+// NodeSchedule 关键路径分析中单个节点的时间参数
+type NodeSchedule struct {
+	ID             string
+	Weight         float64 // 节点的耗时或成本
+	EarliestStart  float64 // 最早开始时间
+	EarliestFinish float64 // 最早完成时间
+	LatestStart    float64 // 不影响总工期的最晚开始时间
+	LatestFinish   float64 // 不影响总工期的最晚完成时间
+	Slack          float64 // 可延迟的时间，为 0 时节点位于关键路径上
+}
+
+// Critical 节点是否位于关键路径上
+func (s NodeSchedule) Critical() bool {
+	return s.Slack <= criticalEpsilon
+}
+
+// criticalEpsilon 浮点数误差范围
+const criticalEpsilon = 1e-9
+
+// CriticalPathResult 关键路径分析的结果
+type CriticalPathResult struct {
+	Order     []string                // 节点的拓扑序
+	Schedules map[string]NodeSchedule // 每个节点的时间参数
+	Path      []string                // 关键路径，即带权重最长的路径
+	Length    float64                 // 关键路径的总长度，即总工期
+}
+
+// CriticalPath 计算带权重的关键路径，weights 为每个节点的耗时或成本，未设置的节点视为 0
+// 节点只有在所有前置节点完成后才能开始，opt 可以指定是否考虑条件边
+func (dag *DAG) CriticalPath(weights map[string]float64, opt ...SortOption) (*CriticalPathResult, error) {
+	for id, weight := range weights {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("节点 %s 的权重 %v 无效", id, weight)
+		}
+	}
+	order, err := dag.TopologicalSort(opt...)
+	if err != nil {
+		return nil, err
+	}
+	succ := dag.successors(newSortConfig(opt...))
+
+	result := &CriticalPathResult{
+		Order:     order,
+		Schedules: make(map[string]NodeSchedule, len(order)),
+	}
+	// 正向计算最早开始时间，并记录决定最早开始时间的前置节点
+	prev := make(map[string]string, len(order))
+	for _, id := range order {
+		s := result.Schedules[id]
+		s.ID = id
+		s.Weight = weights[id]
+		s.EarliestFinish = s.EarliestStart + s.Weight
+		result.Schedules[id] = s
+		for _, childID := range dag.sortedIDs(succ[id]) {
+			child := result.Schedules[childID]
+			if _, ok := prev[childID]; !ok || s.EarliestFinish > child.EarliestStart {
+				child.EarliestStart = s.EarliestFinish
+				prev[childID] = id
+			}
+			result.Schedules[childID] = child
+		}
+	}
+
+	// 总工期及关键路径的终点，"" 也是合法的节点 ID，因此用 found 标记是否已有终点
+	var (
+		end   string
+		found bool
+	)
+	for _, id := range order {
+		if s := result.Schedules[id]; !found || s.EarliestFinish > result.Length {
+			result.Length = s.EarliestFinish
+			end, found = id, true
+		}
+	}
+
+	// 反向计算最晚开始时间
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		s := result.Schedules[id]
+		s.LatestFinish = result.Length
+		for childID := range succ[id] {
+			s.LatestFinish = math.Min(s.LatestFinish, result.Schedules[childID].LatestStart)
+		}
+		s.LatestStart = s.LatestFinish - s.Weight
+		s.Slack = s.LatestStart - s.EarliestStart
+		result.Schedules[id] = s
+	}
+
+	// 从终点沿前置节点回溯得到关键路径
+	for id, ok := end, found; ok; id, ok = prev[id] {
+		result.Path = append([]string{id}, result.Path...)
+	}
+	return result, nil
+}
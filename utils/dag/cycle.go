@@ -0,0 +1,133 @@
+package dag
+
+import "sort"
+
+// topoOrder 使用 Pearce–Kelly 算法增量维护的拓扑序，用于快速检查添加边是否会导致环
+// 拓扑序覆盖 Edges 中的所有边(包括尚未生效的条件边)，与 createsCycle 的检查范围一致
+// 添加边 x->y 时，若 ord[x] < ord[y] 则直接返回；否则只在 [ord[y], ord[x]] 区间内搜索并重排受影响的节点
+type topoOrder[K comparable] struct {
+	ord   map[K]int            // 节点在拓扑序中的位置
+	preds map[K]map[K]struct{} // 节点 -> 前驱节点
+	next  int                  // 下一个新节点的位置
+}
+
+// newTopoOrder 创建空图的拓扑序，之后随节点和边的增删增量维护
+func newTopoOrder[K comparable]() *topoOrder[K] {
+	return &topoOrder[K]{
+		ord:   make(map[K]int),
+		preds: make(map[K]map[K]struct{}),
+	}
+}
+
+// forEachSuccessor 遍历节点的所有后继节点，包括尚未生效的条件边的目标节点
+func (g *graph[K, V, E]) forEachSuccessor(id K, fn func(childID K)) {
+	if node, exists := g.Nodes[id]; exists {
+		for childID := range node.Children {
+			fn(childID)
+		}
+	}
+	for childID := range g.pending[id] {
+		fn(childID)
+	}
+}
+
+func (t *topoOrder[K]) addPred(from, to K) {
+	if t.preds[to] == nil {
+		t.preds[to] = make(map[K]struct{})
+	}
+	t.preds[to][from] = struct{}{}
+}
+
+// addNode 新节点追加到拓扑序的末尾
+func (t *topoOrder[K]) addNode(id K) {
+	t.ord[id] = t.next
+	t.next++
+}
+
+// removeEdge 移除边，移除边不会破坏拓扑序
+func (t *topoOrder[K]) removeEdge(from, to K) {
+	if preds, ok := t.preds[to]; ok {
+		delete(preds, from)
+		if len(preds) == 0 {
+			delete(t.preds, to)
+		}
+	}
+}
+
+// removeNode 移除节点，调用前需要先移除与它相连的边
+func (t *topoOrder[K]) removeNode(id K) {
+	delete(t.ord, id)
+	delete(t.preds, id)
+}
+
+// addEdgeOrder 尝试添加边 from->to 并维护拓扑序
+// 返回值：添加该边是否会导致环，导致环时拓扑序保持不变
+func (g *graph[K, V, E]) addEdgeOrder(from, to K) bool {
+	t := g.topoIdx
+	if from == to {
+		return true
+	}
+	lb, ub := t.ord[to], t.ord[from]
+	if lb > ub {
+		t.addPred(from, to)
+		return false
+	}
+
+	// 正向搜索：从 to 出发，只访问位置不超过 ub 的节点，遇到 from 说明存在环
+	visited := make(map[K]bool)
+	var forward []K
+	var cycle bool
+	var dfsF func(id K)
+	dfsF = func(id K) {
+		visited[id] = true
+		forward = append(forward, id)
+		g.forEachSuccessor(id, func(childID K) {
+			if cycle {
+				return
+			}
+			if t.ord[childID] == ub {
+				cycle = true
+				return
+			}
+			if !visited[childID] && t.ord[childID] < ub {
+				dfsF(childID)
+			}
+		})
+	}
+	dfsF(to)
+	if cycle {
+		return true
+	}
+
+	// 反向搜索：从 from 出发，只访问位置大于 lb 的节点
+	var backward []K
+	var dfsB func(id K)
+	dfsB = func(id K) {
+		visited[id] = true
+		backward = append(backward, id)
+		for parentID := range t.preds[id] {
+			if !visited[parentID] && t.ord[parentID] > lb {
+				dfsB(parentID)
+			}
+		}
+	}
+	dfsB(from)
+
+	// 重排：受影响的节点保持各自的相对顺序，反向搜索到的节点整体排在正向搜索到的节点之前
+	byOrd := func(ids []K) {
+		sort.Slice(ids, func(i, j int) bool { return t.ord[ids[i]] < t.ord[ids[j]] })
+	}
+	byOrd(backward)
+	byOrd(forward)
+	nodes := append(backward, forward...)
+	positions := make([]int, len(nodes))
+	for i, id := range nodes {
+		positions[i] = t.ord[id]
+	}
+	sort.Ints(positions)
+	for i, id := range nodes {
+		t.ord[id] = positions[i]
+	}
+	t.addPred(from, to)
+	return false
+}
@@ -3,78 +3,401 @@ package dag
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 )
 
 // Node 表示 DAG 中的一个节点
-type Node struct {
-	ID       string           // 节点 ID
-	Children map[string]*Node // 子节点的映射
+type Node = TypedNode[string, struct{}]
+
+// Order 查询结果中节点的排列顺序
+type Order int
+
+const (
+	OrderSorted    Order = iota // 按节点 ID 排列，字符串按字典序，数值按大小
+	OrderInsertion              // 按节点加入 DAG 的顺序排列
+)
+
+type dagConfig struct {
+	order Order
+}
+
+// DAGOption 创建 DAG 或 TypedDAG 时的可选项
+type DAGOption func(*dagConfig)
+
+// WithOrder 设置查询结果中节点的排列顺序，DAG 默认按 ID 字典序排列，TypedDAG 默认按插入顺序排列
+func WithOrder(order Order) DAGOption {
+	return func(c *dagConfig) {
+		c.order = order
+	}
+}
+
+func newDAGConfig(order Order, opt ...DAGOption) *dagConfig {
+	conf := &dagConfig{order: order}
+	for i := range opt {
+		opt[i](conf)
+	}
+	return conf
 }
 
-// DAG 表示有向无环图
+// DAG 表示有向无环图，即节点 ID 为字符串、节点不携带数据的 TypedDAG
+// Edges 中边的 key 为 "from->to" 形式，节点 ID 包含 "->" 时两条不同的边可能得到相同的 key，后添加的边会返回错误
+// Nodes 和 Edges 只应通过 AddNode、AddConditionalEdge、RemoveNode 等方法修改：直接修改这两个 map
+// 不会更新增量维护的拓扑序、等待中的边和可达性缓存，之后的环检测和查询结果可能不正确
 type DAG struct {
-	Nodes map[string]*Node    // 图中所有节点的映射
-	Edges map[string][]string // 边的映射，存储条件边
+	graph[string, struct{}, string]
 }
 
+// PendingEdge 因条件节点不存在而尚未生效的条件边
+type PendingEdge = TypedPendingEdge[string]
+
 // NewDAG 创建一个新的空 DAG
-func NewDAG() *DAG {
+func NewDAG(opt ...DAGOption) *DAG {
 	return &DAG{
-		Nodes: make(map[string]*Node),
-		Edges: make(map[string][]string),
+		graph: newGraph[string, struct{}](newDAGConfig(OrderSorted, opt...), joinEdgeKey,
+			func(a, b string) bool { return a < b }),
 	}
 }
 
+// joinEdgeKey 返回 "from->to" 形式的边 key
+// 节点 ID 可以包含 "->"，因此 key 不能反向拆分，边的两端另外记录在 graph.ends 中
+func joinEdgeKey(from, to string) string {
+	return from + "->" + to
+}
+
 // AddNode 向 DAG 中添加一个节点
+// 等待该节点作为条件的边会被重新检查，条件全部满足时连接到 Children
 func (dag *DAG) AddNode(id string) {
-	if _, exists := dag.Nodes[id]; !exists {
-		dag.Nodes[id] = &Node{
-			ID:       id,
-			Children: make(map[string]*Node),
+	dag.addNode(id, struct{}{})
+}
+
+// graph DAG 与 TypedDAG 共用的实现
+// K 为节点 ID 的类型，V 为节点携带的数据，E 为 Edges 中边的 key：DAG 使用 "from->to" 形式的字符串，TypedDAG 使用 Edge[K]
+type graph[K comparable, V any, E comparable] struct {
+	Nodes map[K]*TypedNode[K, V] // 图中所有节点的映射
+	Edges map[E][]K              // 边的映射，存储条件边
+	// 因条件节点不存在而尚未连接到 Children 的边
+	waiting map[K]map[E]struct{}    // 缺失的条件节点 -> 等待它的边 key
+	ends    map[E]Edge[K]           // 边 key -> 源节点和目标节点，key 不会被重新解析
+	pending map[K]map[K]struct{}    // 源节点 -> 尚未连接的目标节点
+	memo    *reachCache[K, E]       // 可达性查询的缓存，图结构变化时清空
+	exprs   map[E]TypedCondition[K] // 使用条件表达式的边，Edges 中存储表达式引用的节点
+	order   Order                   // 查询结果的排列顺序
+	seq     uint64                  // 节点序号计数
+	topoIdx *topoOrder[K]           // 增量维护的拓扑序，用于环检测
+	edgeKey func(from, to K) E      // 返回边在 Edges 中的 key
+	cmp     func(a, b K) bool       // 节点 ID 的大小比较，为 nil 时 OrderSorted 按插入顺序排列
+}
+
+func newGraph[K comparable, V any, E comparable](conf *dagConfig, edgeKey func(from, to K) E,
+	cmp func(a, b K) bool) graph[K, V, E] {
+	return graph[K, V, E]{
+		Nodes:   make(map[K]*TypedNode[K, V]),
+		Edges:   make(map[E][]K),
+		waiting: make(map[K]map[E]struct{}),
+		ends:    make(map[E]Edge[K]),
+		pending: make(map[K]map[K]struct{}),
+		memo:    &reachCache[K, E]{},
+		topoIdx: newTopoOrder[K](),
+		order:   conf.order,
+		edgeKey: edgeKey,
+		cmp:     cmp,
+	}
+}
+
+// orderedLess K 为字符串或数值类型时返回按大小比较的函数，否则返回 nil
+func orderedLess[K comparable]() func(a, b K) bool {
+	switch reflect.TypeFor[K]().Kind() {
+	case reflect.String:
+		return func(a, b K) bool { return reflect.ValueOf(a).String() < reflect.ValueOf(b).String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b K) bool { return reflect.ValueOf(a).Int() < reflect.ValueOf(b).Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b K) bool { return reflect.ValueOf(a).Uint() < reflect.ValueOf(b).Uint() }
+	case reflect.Float32, reflect.Float64:
+		return func(a, b K) bool { return reflect.ValueOf(a).Float() < reflect.ValueOf(b).Float() }
+	}
+	return nil
+}
+
+// less 按设置的顺序比较两个节点
+func (g *graph[K, V, E]) less(a, b K) bool {
+	if g.order == OrderInsertion || g.cmp == nil {
+		na, nb := g.Nodes[a], g.Nodes[b]
+		if na != nil && nb != nil && na.seq != nb.seq {
+			return na.seq < nb.seq
 		}
+		if g.cmp == nil {
+			return false
+		}
+	}
+	return g.cmp(a, b)
+}
+
+// sortIDs 按设置的顺序排列节点
+func (g *graph[K, V, E]) sortIDs(ids []K) {
+	sort.Slice(ids, func(i, j int) bool {
+		return g.less(ids[i], ids[j])
+	})
+}
+
+// sortedIDs 返回集合中按设置的顺序排列的节点
+func (g *graph[K, V, E]) sortedIDs(set map[K]struct{}) []K {
+	result := make([]K, 0, len(set))
+	for id := range set {
+		result = append(result, id)
+	}
+	g.sortIDs(result)
+	return result
+}
+
+// lessEdge 按源节点、目标节点的顺序比较两条边
+func (g *graph[K, V, E]) lessEdge(fromA, toA, fromB, toB K) bool {
+	if fromA != fromB {
+		return g.less(fromA, fromB)
+	}
+	return g.less(toA, toB)
+}
+
+// addNode 添加一个节点，节点已存在时不做修改
+// 返回值：是否新增了节点
+func (g *graph[K, V, E]) addNode(id K, value V) bool {
+	if _, exists := g.Nodes[id]; exists {
+		return false
 	}
+	g.seq++
+	g.Nodes[id] = &TypedNode[K, V]{
+		ID:       id,
+		Value:    value,
+		Children: make(map[K]*TypedNode[K, V]),
+		seq:      g.seq,
+	}
+	g.resetReach()
+	g.topoIdx.addNode(id)
+	keys := g.waiting[id]
+	delete(g.waiting, id)
+	for key := range keys {
+		edge := g.ends[key]
+		g.connect(edge.From, edge.To)
+	}
+	return true
 }
 
 // AddConditionalEdge 添加一条有条件的从 `from` 节点到 `to` 节点的有向边
 // 只有当所有条件节点都存在时，目标节点才可达
-func (dag *DAG) AddConditionalEdge(from, to string, conditions []string) error {
-	if _, exists := dag.Nodes[from]; !exists {
+// 条件节点不存在时边处于等待状态(见 PendingEdges)，条件节点通过 AddNode 加入后自动生效
+func (g *graph[K, V, E]) AddConditionalEdge(from, to K, conditions []K) error {
+	if _, exists := g.Nodes[from]; !exists {
 		return errors.New("源节点不存在")
 	}
-	if _, exists := dag.Nodes[to]; !exists {
+	if _, exists := g.Nodes[to]; !exists {
 		return errors.New("目标节点不存在")
 	}
+	key := g.edgeKey(from, to)
+	if edge, exists := g.ends[key]; exists && (edge.From != from || edge.To != to) {
+		return fmt.Errorf("边 %v->%v 与已有的边 %v->%v 的 key 相同", from, to, edge.From, edge.To)
+	}
 
 	// 检查添加该边是否会导致环的产生
-	if dag.createsCycle(from, to) {
+	if g.addEdgeOrder(from, to) {
 		return errors.New("添加此边会导致环")
 	}
+	g.storeEdge(key, from, to, conditions)
+	return nil
+}
 
-	// 将条件边存储起来
-	//key := fmt.Sprintf("%s->%s", from, to)
-	key := from + "->" + to
-	dag.Edges[key] = conditions
+// storeEdge 将已经通过环检查的条件边存储起来，重复添加时以新的条件为准
+func (g *graph[K, V, E]) storeEdge(key E, from, to K, conditions []K) {
+	if _, exists := g.Edges[key]; exists {
+		g.disconnect(from, to)
+	}
+	delete(g.exprs, key)
+	g.Edges[key] = conditions
+	g.ends[key] = Edge[K]{From: from, To: to}
+	g.resetReach()
+	g.connect(from, to)
+}
 
-	// 检查所有条件节点是否存在
-	for _, condition := range conditions {
-		if _, exists := dag.Nodes[condition]; !exists {
-			return nil // 如果条件节点不存在，不添加边
+// AddExprEdge 添加一条以条件表达式控制的从 `from` 节点到 `to` 节点的有向边
+// 表达式引用的节点全部存在时边才会生效，GetDirectlyReachableNodes 根据已完成的节点对表达式求值
+func (g *graph[K, V, E]) AddExprEdge(from, to K, cond TypedCondition[K]) error {
+	if cond == nil {
+		return errors.New("条件表达式为空")
+	}
+	if err := g.AddConditionalEdge(from, to, cond.Nodes()); err != nil {
+		return err
+	}
+	if g.exprs == nil {
+		g.exprs = make(map[E]TypedCondition[K])
+	}
+	g.exprs[g.edgeKey(from, to)] = cond
+	return nil
+}
+
+// EdgeCondition 返回边的条件表达式，使用条件列表的边返回等价的 AND 表达式
+func (g *graph[K, V, E]) EdgeCondition(from, to K) (TypedCondition[K], bool) {
+	key := g.edgeKey(from, to)
+	if cond, ok := g.exprs[key]; ok {
+		return cond, true
+	}
+	conditions, ok := g.Edges[key]
+	if !ok {
+		return nil, false
+	}
+	return AllOf(conditions...), true
+}
+
+// RemoveNode 从 DAG 中移除一个节点
+// 同时移除以该节点为源节点、目标节点或条件节点的边，以及其他节点 Children 中对它的引用
+func (g *graph[K, V, E]) RemoveNode(id K) error {
+	if _, exists := g.Nodes[id]; !exists {
+		return fmt.Errorf("节点 %v 不存在", id)
+	}
+
+	var removed []E
+	for key, conditions := range g.Edges {
+		edge := g.ends[key]
+		if edge.From == id || edge.To == id || contains(conditions, id) {
+			removed = append(removed, key)
 		}
 	}
+	for _, key := range removed {
+		edge := g.ends[key]
+		g.disconnect(edge.From, edge.To)
+		delete(g.Edges, key)
+		delete(g.ends, key)
+		delete(g.exprs, key)
+		g.topoIdx.removeEdge(edge.From, edge.To)
+	}
+	g.topoIdx.removeNode(id)
+
+	delete(g.Nodes, id)
+	for _, node := range g.Nodes {
+		delete(node.Children, id)
+	}
+	g.resetReach()
+	return nil
+}
 
-	// 如果所有条件节点都存在，则添加边
-	dag.Nodes[from].Children[to] = dag.Nodes[to]
+// RemoveEdge 移除从 `from` 节点到 `to` 节点的边
+func (g *graph[K, V, E]) RemoveEdge(from, to K) error {
+	if _, exists := g.Nodes[from]; !exists {
+		return fmt.Errorf("节点 %v 不存在", from)
+	}
+	if _, exists := g.Nodes[to]; !exists {
+		return fmt.Errorf("节点 %v 不存在", to)
+	}
+	key := g.edgeKey(from, to)
+	if _, exists := g.Edges[key]; !exists {
+		if _, exists = g.Nodes[from].Children[to]; !exists {
+			return fmt.Errorf("边 %v->%v 不存在", from, to)
+		}
+	}
+	g.disconnect(from, to)
+	delete(g.Edges, key)
+	delete(g.ends, key)
+	delete(g.exprs, key)
+	g.topoIdx.removeEdge(from, to)
 	return nil
 }
 
-// createsCycle 检查添加边是否会导致环，使用 DFS 实现
-func (dag *DAG) createsCycle(from, to string) bool {
-	visited := make(map[string]bool)  // 记录访问过的节点
-	return dag.dfs(to, from, visited) // 深度优先搜索
+// connect 检查边的所有条件节点是否存在，存在则将边连接到 Children，否则记录为等待中的边
+func (g *graph[K, V, E]) connect(from, to K) {
+	key := g.edgeKey(from, to)
+	missing := g.missingConditions(g.Edges[key])
+	if len(missing) == 0 {
+		// 如果所有条件节点都存在，则添加边
+		g.removePending(from, to)
+		g.Nodes[from].Children[to] = g.Nodes[to]
+		g.resetReach()
+		return
+	}
+	// 如果条件节点不存在，暂不添加边，等待条件节点加入
+	if g.waiting == nil {
+		g.waiting = make(map[K]map[E]struct{})
+	}
+	if g.pending == nil {
+		g.pending = make(map[K]map[K]struct{})
+	}
+	for _, condition := range missing {
+		if g.waiting[condition] == nil {
+			g.waiting[condition] = make(map[E]struct{})
+		}
+		g.waiting[condition][key] = struct{}{}
+	}
+	if g.pending[from] == nil {
+		g.pending[from] = make(map[K]struct{})
+	}
+	g.pending[from][to] = struct{}{}
+}
+
+// disconnect 断开边在 Children 中的连接，并清除等待记录
+func (g *graph[K, V, E]) disconnect(from, to K) {
+	key := g.edgeKey(from, to)
+	if node, exists := g.Nodes[from]; exists {
+		delete(node.Children, to)
+	}
+	g.resetReach()
+	for _, condition := range g.Edges[key] {
+		if keys, ok := g.waiting[condition]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(g.waiting, condition)
+			}
+		}
+	}
+	g.removePending(from, to)
+}
+
+// removePending 移除等待中的边记录
+func (g *graph[K, V, E]) removePending(from, to K) {
+	if targets, ok := g.pending[from]; ok {
+		delete(targets, to)
+		if len(targets) == 0 {
+			delete(g.pending, from)
+		}
+	}
+}
+
+// missingConditions 返回不存在的条件节点
+func (g *graph[K, V, E]) missingConditions(conditions []K) []K {
+	var missing []K
+	for _, condition := range conditions {
+		if _, exists := g.Nodes[condition]; !exists {
+			missing = append(missing, condition)
+		}
+	}
+	return missing
+}
+
+// PendingEdges 返回因条件节点不存在而尚未生效的条件边，按源节点、目标节点排序(顺序见 WithOrder)
+func (g *graph[K, V, E]) PendingEdges() []TypedPendingEdge[K] {
+	result := []TypedPendingEdge[K]{}
+	for from, targets := range g.pending {
+		for to := range targets {
+			result = append(result, TypedPendingEdge[K]{
+				From:    from,
+				To:      to,
+				Missing: g.missingConditions(g.Edges[g.edgeKey(from, to)]),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return g.lessEdge(result[i].From, result[i].To, result[j].From, result[j].To)
+	})
+	return result
+}
+
+// createsCycle 检查添加边是否会导致环，使用 DFS 实现，每次检查都需要遍历 to 的所有后代
+// 尚未生效的条件边同样参与检查，避免条件节点加入后出现环
+// AddConditionalEdge 已改用增量的 addEdgeOrder，这里保留作为对照实现
+func (g *graph[K, V, E]) createsCycle(from, to K) bool {
+	visited := make(map[K]bool)     // 记录访问过的节点
+	return g.dfs(to, from, visited) // 深度优先搜索
 }
 
 // dfs 是一个辅助函数，用于在 createsCycle 中执行深度优先搜索
-func (dag *DAG) dfs(current, target string, visited map[string]bool) bool {
+func (g *graph[K, V, E]) dfs(current, target K, visited map[K]bool) bool {
 	if current == target {
 		return true // 找到环
 	}
@@ -84,8 +407,13 @@ func (dag *DAG) dfs(current, target string, visited map[string]bool) bool {
 	visited[current] = true // 标记当前节点为已访问
 
 	// 遍历当前节点的子节点，递归检查是否存在环
-	for childID := range dag.Nodes[current].Children {
-		if dag.dfs(childID, target, visited) {
+	for childID := range g.Nodes[current].Children {
+		if g.dfs(childID, target, visited) {
+			return true
+		}
+	}
+	for childID := range g.pending[current] {
+		if g.dfs(childID, target, visited) {
 			return true
 		}
 	}
@@ -93,24 +421,35 @@ func (dag *DAG) dfs(current, target string, visited map[string]bool) bool {
 }
 
 // GetDirectlyReachableNodes 返回从给定节点数组出发直接可到达的所有节点，不包括起始节点本身
-func (dag *DAG) GetDirectlyReachableNodes(startNodes []string) ([]string, error) {
+// 结果的顺序见 WithOrder
+func (g *graph[K, V, E]) GetDirectlyReachableNodes(startNodes []K) ([]K, error) {
 	// 如果 startNodes 为空，返回没有前置节点的节点
 	if len(startNodes) == 0 {
-		return dag.getNodesWithoutPredecessors(), nil
+		return g.getNodesWithoutPredecessors(), nil
 	}
 
-	reachable := make(map[string]bool)
+	reachable := make(map[K]bool)
+	var completed map[K]bool // 条件表达式求值时使用，按需创建
 
 	for _, id := range startNodes {
-		if _, exists := dag.Nodes[id]; !exists {
-			return nil, fmt.Errorf("节点 %s 不存在", id)
+		if _, exists := g.Nodes[id]; !exists {
+			return nil, fmt.Errorf("节点 %v 不存在", id)
 		}
-		for childID := range dag.Nodes[id].Children {
+		for childID := range g.Nodes[id].Children {
 			if !contains(startNodes, childID) {
 				// 检查条件边
-				//key := fmt.Sprintf("%s->%s", id, childID)
-				key := id + "->" + childID
-				if conditions, exists := dag.Edges[key]; exists {
+				key := g.edgeKey(id, childID)
+				if expr, exists := g.exprs[key]; exists {
+					if completed == nil {
+						completed = make(map[K]bool, len(startNodes))
+						for _, n := range startNodes {
+							completed[n] = true
+						}
+					}
+					if expr.Eval(completed) {
+						reachable[childID] = true
+					}
+				} else if conditions, exists := g.Edges[key]; exists {
 					if allConditionsMet(startNodes, conditions) {
 						reachable[childID] = true
 					}
@@ -121,16 +460,31 @@ func (dag *DAG) GetDirectlyReachableNodes(startNodes []string) ([]string, error)
 		}
 	}
 
-	result := make([]string, 0, len(reachable))
+	result := make([]K, 0, len(reachable))
 	for node := range reachable {
 		result = append(result, node)
 	}
+	g.sortIDs(result)
 
 	return result, nil
 }
 
+// edgeSatisfied 检查边 from->to 的条件在已完成的节点集合下是否满足
+func (g *graph[K, V, E]) edgeSatisfied(from, to K, completed map[K]bool) bool {
+	key := g.edgeKey(from, to)
+	if expr, exists := g.exprs[key]; exists {
+		return expr.Eval(completed)
+	}
+	for _, condition := range g.Edges[key] {
+		if !completed[condition] {
+			return false
+		}
+	}
+	return true
+}
+
 // allConditionsMet 检查所有条件节点是否都在起始节点数组中
-func allConditionsMet(startNodes, conditions []string) bool {
+func allConditionsMet[K comparable](startNodes, conditions []K) bool {
 	for _, condition := range conditions {
 		if !contains(startNodes, condition) {
 			return false
@@ -140,7 +494,7 @@ func allConditionsMet(startNodes, conditions []string) bool {
 }
 
 // contains 检查切片中是否包含指定元素
-func contains(slice []string, item string) bool {
+func contains[K comparable](slice []K, item K) bool {
 	for _, s := range slice {
 		if s == item {
 			return true
@@ -150,28 +504,29 @@ func contains(slice []string, item string) bool {
 }
 
 // getNodesWithoutPredecessors 返回没有前置节点的节点
-func (dag *DAG) getNodesWithoutPredecessors() []string {
-	inDegree := make(map[string]int)
+func (g *graph[K, V, E]) getNodesWithoutPredecessors() []K {
+	inDegree := make(map[K]int)
 
 	// 初始化所有节点的入度为0
-	for id := range dag.Nodes {
+	for id := range g.Nodes {
 		inDegree[id] = 0
 	}
 
 	// 计算节点的入度
-	for _, node := range dag.Nodes {
+	for _, node := range g.Nodes {
 		for childID := range node.Children {
 			inDegree[childID]++
 		}
 	}
 
 	// 找出入度为0的节点
-	result := []string{}
+	result := []K{}
 	for id, degree := range inDegree {
 		if degree == 0 {
 			result = append(result, id)
 		}
 	}
+	g.sortIDs(result)
 
 	return result
 }
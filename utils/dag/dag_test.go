@@ -1,8 +1,18 @@
 package dag
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestDAG(t *testing.T) {
@@ -71,3 +81,803 @@ func TestDAG(t *testing.T) {
 		fmt.Println("从节点 [] 出发直接可到达的节点:", reachable)
 	}
 }
+
+// newTestDAG 创建测试用的 DAG：A->B, A->C(条件B), B->C(条件A), C->D, D->E, F
+func newTestDAG() *DAG {
+	dag := NewDAG()
+	for _, id := range []string{"A", "B", "C", "D", "E", "F"} {
+		dag.AddNode(id)
+	}
+	_ = dag.AddConditionalEdge("A", "B", nil)
+	_ = dag.AddConditionalEdge("A", "C", []string{"B"})
+	_ = dag.AddConditionalEdge("B", "C", []string{"A"})
+	_ = dag.AddConditionalEdge("C", "D", nil)
+	_ = dag.AddConditionalEdge("D", "E", nil)
+	return dag
+}
+
+func TestTopologicalSort(t *testing.T) {
+	ast := assert.New(t)
+	dag := newTestDAG()
+	order, err := dag.TopologicalSort()
+	ast.NoError(err)
+	ast.Equal([]string{"A", "B", "C", "D", "E", "F"}, order)
+
+	levels, err := dag.Levels()
+	ast.NoError(err)
+	ast.Equal([][]string{{"A", "F"}, {"B"}, {"C"}, {"D"}, {"E"}}, levels)
+
+	// 条件节点 X 不存在时边不会连接，考虑条件边后 F 需要在 E 之后
+	dag.AddNode("G")
+	_ = dag.AddConditionalEdge("E", "F", []string{"X"})
+	levels, err = dag.Levels()
+	ast.NoError(err)
+	ast.Equal([][]string{{"A", "F", "G"}, {"B"}, {"C"}, {"D"}, {"E"}}, levels)
+	levels, err = dag.Levels(WithConditionalEdges())
+	ast.NoError(err)
+	ast.Equal([][]string{{"A", "G"}, {"B"}, {"C"}, {"D"}, {"E"}, {"F"}}, levels)
+
+	// 条件节点作为前置节点时产生的环
+	_ = dag.AddConditionalEdge("G", "A", []string{"E"})
+	_, err = dag.TopologicalSort(WithConditionalEdges())
+	ast.ErrorIs(err, ErrCycle)
+	_, err = dag.Levels(WithConditionalEdges())
+	ast.ErrorIs(err, ErrCycle)
+}
+
+func TestExecutor(t *testing.T) {
+	t.Run("按依赖顺序并发执行", func(t *testing.T) {
+		ast := assert.New(t)
+		dag := newTestDAG()
+		exec := NewExecutor(dag, WithConcurrency(2))
+		var (
+			mu    sync.Mutex
+			order []string
+		)
+		for id := range dag.Nodes {
+			id := id
+			ast.NoError(exec.SetTask(id, func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, id)
+				return nil
+			}))
+		}
+		ast.Error(exec.SetTask("X", nil))
+		report, err := exec.Run(context.Background())
+		ast.NoError(err)
+		ast.Len(report.IDsWithStatus(TaskSucceeded), 6)
+		index := func(id string) int {
+			for i, v := range order {
+				if v == id {
+					return i
+				}
+			}
+			return -1
+		}
+		ast.Less(index("A"), index("B"))
+		ast.Less(index("B"), index("C"))
+		ast.Less(index("C"), index("D"))
+		ast.Less(index("D"), index("E"))
+	})
+	t.Run("失败策略", func(t *testing.T) {
+		ast := assert.New(t)
+		dag := newTestDAG()
+		failErr := errors.New("fail")
+		exec := NewExecutor(dag, WithConcurrency(1))
+		_ = exec.SetTask("B", func(ctx context.Context) error {
+			return failErr
+		})
+		report, err := exec.Run(context.Background())
+		ast.ErrorIs(err, failErr)
+		ast.Equal([]string{"B"}, report.IDsWithStatus(TaskFailed))
+		ast.Equal([]string{"C", "D", "E"}, report.IDsWithStatus(TaskSkipped))
+
+		exec = NewExecutor(dag, WithConcurrency(1), WithErrorPolicy(ContinueOnError))
+		_ = exec.SetTask("B", func(ctx context.Context) error {
+			panic("boom")
+		})
+		report, err = exec.Run(context.Background())
+		ast.Error(err)
+		ast.Equal([]string{"A", "F"}, report.IDsWithStatus(TaskSucceeded))
+		ast.Equal([]string{"B"}, report.IDsWithStatus(TaskFailed))
+		ast.Equal([]string{"C", "D", "E"}, report.IDsWithStatus(TaskSkipped))
+	})
+	t.Run("取消", func(t *testing.T) {
+		ast := assert.New(t)
+		dag := newTestDAG()
+		ctx, cancel := context.WithCancel(context.Background())
+		exec := NewExecutor(dag, WithConcurrency(1))
+		_ = exec.SetTask("A", func(ctx context.Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		report, err := exec.Run(ctx)
+		ast.ErrorIs(err, context.Canceled)
+		ast.Equal([]string{"A", "B", "C", "D", "E", "F"}, report.IDsWithStatus(TaskCanceled))
+	})
+	t.Run("失败后中断的任务标记为取消", func(t *testing.T) {
+		ast := assert.New(t)
+		dag := NewDAG()
+		for _, id := range []string{"A", "B", "C"} {
+			dag.AddNode(id)
+		}
+		failErr := errors.New("boom")
+		started := make(chan struct{}, 2)
+		exec := NewExecutor(dag, WithConcurrency(3))
+		_ = exec.SetTask("A", func(ctx context.Context) error {
+			<-started
+			<-started
+			return failErr
+		})
+		for _, id := range []string{"B", "C"} {
+			_ = exec.SetTask(id, func(ctx context.Context) error {
+				started <- struct{}{}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		}
+		report, err := exec.Run(context.Background())
+		ast.ErrorIs(err, failErr)
+		ast.NotErrorIs(err, context.Canceled)
+		ast.Equal([]string{"A"}, report.IDsWithStatus(TaskFailed))
+		ast.Equal([]string{"B", "C"}, report.IDsWithStatus(TaskCanceled))
+	})
+	t.Run("条件节点晚于父节点完成", func(t *testing.T) {
+		ast := assert.New(t)
+		dag := NewDAG()
+		dag.AddNode("A")
+		dag.AddNode("B")
+		dag.AddNode("C")
+		ast.NoError(dag.AddConditionalEdge("A", "C", []string{"B"}))
+		exec := NewExecutor(dag, WithConcurrency(1))
+		var order []string
+		for _, id := range []string{"A", "B", "C"} {
+			id := id
+			_ = exec.SetTask(id, func(ctx context.Context) error {
+				order = append(order, id)
+				return nil
+			})
+		}
+		report, err := exec.Run(context.Background())
+		ast.NoError(err)
+		ast.Equal([]string{"A", "B", "C"}, report.IDsWithStatus(TaskSucceeded))
+		ast.Equal([]string{"A", "B", "C"}, order)
+	})
+}
+
+func TestPendingEdges(t *testing.T) {
+	ast := assert.New(t)
+	dag := NewDAG()
+	dag.AddNode("A")
+	dag.AddNode("B")
+	ast.NoError(dag.AddConditionalEdge("A", "B", []string{"C", "D"}))
+	ast.Empty(dag.Nodes["A"].Children)
+	ast.Equal([]PendingEdge{{From: "A", To: "B", Missing: []string{"C", "D"}}}, dag.PendingEdges())
+
+	dag.AddNode("C")
+	ast.Equal([]PendingEdge{{From: "A", To: "B", Missing: []string{"D"}}}, dag.PendingEdges())
+	ast.Empty(dag.Nodes["A"].Children)
+
+	// 等待中的边同样参与环检测
+	ast.Error(dag.AddConditionalEdge("B", "A", nil))
+
+	dag.AddNode("D")
+	ast.Empty(dag.PendingEdges())
+	ast.Contains(dag.Nodes["A"].Children, "B")
+
+	// 重新添加边时以新的条件为准
+	ast.NoError(dag.AddConditionalEdge("A", "B", []string{"E"}))
+	ast.Empty(dag.Nodes["A"].Children)
+	ast.Equal([]PendingEdge{{From: "A", To: "B", Missing: []string{"E"}}}, dag.PendingEdges())
+
+	// 节点 ID 包含 "->" 时，等待中的边在条件节点加入后连接到正确的节点
+	dag = NewDAG()
+	for _, id := range []string{"a->b", "c", "a", "b->c"} {
+		dag.AddNode(id)
+	}
+	ast.NoError(dag.AddConditionalEdge("a->b", "c", []string{"x"}))
+	ast.NotPanics(func() { dag.AddNode("x") })
+	ast.Contains(dag.Nodes["a->b"].Children, "c")
+	ast.Empty(dag.PendingEdges())
+	// "a"->"b->c" 与 "a->b"->"c" 的 key 相同，不能同时存在
+	ast.Error(dag.AddConditionalEdge("a", "b->c", nil))
+	ast.Equal([]EdgeDefinition{{From: "a->b", To: "c", Conditions: []string{"x"}}}, dag.Definition().Edges)
+}
+
+func TestRemove(t *testing.T) {
+	ast := assert.New(t)
+	dag := newTestDAG()
+	ast.Error(dag.RemoveNode("X"))
+	ast.Error(dag.RemoveEdge("A", "X"))
+	ast.Error(dag.RemoveEdge("A", "D"))
+
+	// 移除 B 后，以 B 为条件的 A->C 同样被移除
+	ast.NoError(dag.RemoveNode("B"))
+	ast.NotContains(dag.Nodes, "B")
+	ast.NotContains(dag.Nodes["A"].Children, "B")
+	ast.NotContains(dag.Edges, "A->B")
+	ast.NotContains(dag.Edges, "B->C")
+	ast.NotContains(dag.Edges, "A->C")
+	ast.NotContains(dag.Nodes["A"].Children, "C")
+	ast.Empty(dag.PendingEdges())
+	dag.AddNode("B")
+	ast.NotContains(dag.Nodes["A"].Children, "C")
+	ast.Empty(dag.PendingEdges())
+
+	ast.NoError(dag.RemoveEdge("C", "D"))
+	ast.NotContains(dag.Nodes["C"].Children, "D")
+	ast.NotContains(dag.Edges, "C->D")
+	ast.Error(dag.RemoveEdge("C", "D"))
+
+	// 等待中的边同样可以移除
+	ast.NoError(dag.AddConditionalEdge("D", "F", []string{"Y"}))
+	ast.NoError(dag.RemoveEdge("D", "F"))
+	ast.Empty(dag.PendingEdges())
+	dag.AddNode("Y")
+	ast.NotContains(dag.Nodes["D"].Children, "F")
+
+	// 节点 ID 包含 "->" 时同样移除所有引用该节点的边
+	dag = NewDAG()
+	dag.AddNode("a->b")
+	dag.AddNode("c")
+	ast.NoError(dag.AddConditionalEdge("a->b", "c", nil))
+	ast.NoError(dag.RemoveNode("c"))
+	ast.Empty(dag.Edges)
+	ast.Empty(dag.Nodes["a->b"].Children)
+	ast.Empty(dag.Definition().Edges)
+	dag.AddNode("c")
+	ast.NoError(dag.AddConditionalEdge("c", "a->b", nil))
+}
+
+func TestSerialize(t *testing.T) {
+	ast := assert.New(t)
+	dag := newTestDAG()
+
+	data, err := json.Marshal(dag)
+	ast.NoError(err)
+	loaded, err := LoadJSON(data)
+	ast.NoError(err)
+	ast.Equal(dag.Definition(), loaded.Definition())
+	ast.Contains(loaded.Nodes["A"].Children, "C")
+
+	data, err = yaml.Marshal(dag)
+	ast.NoError(err)
+	loaded, err = LoadYAML(data)
+	ast.NoError(err)
+	ast.Equal(dag.Definition(), loaded.Definition())
+
+	var loadErr *LoadError
+	_, err = LoadYAML([]byte(`nodes: [A, B]
+edges:
+  - {from: A, to: B}
+  - {from: B, to: A}
+`))
+	ast.ErrorAs(err, &loadErr)
+	ast.Equal(4, loadErr.Line)
+
+	_, err = LoadYAML([]byte(`nodes: [A, B]
+edges:
+  - {from: A, to: B, conditions: [C]}
+`))
+	ast.ErrorAs(err, &loadErr)
+	ast.Equal(3, loadErr.Line)
+
+	_, err = LoadJSON([]byte(`{
+  "nodes": ["A", "A"]
+}`))
+	ast.ErrorAs(err, &loadErr)
+	ast.Equal(2, loadErr.Line)
+
+	_, err = LoadJSON([]byte(`{
+  "nodes": ["A",
+}`))
+	ast.ErrorAs(err, &loadErr)
+	ast.Equal(3, loadErr.Line)
+
+	// 加载失败时原 DAG 保持不变
+	ast.Error(json.Unmarshal([]byte(`{"nodes": ["A"], "edges": [{"from": "A", "to": "X"}]}`), dag))
+	ast.Len(dag.Nodes, 6)
+}
+
+func TestExport(t *testing.T) {
+	ast := assert.New(t)
+	dag := NewDAG()
+	dag.AddNode("A")
+	dag.AddNode("B")
+	dag.AddNode("C")
+	_ = dag.AddConditionalEdge("A", "B", nil)
+	_ = dag.AddConditionalEdge("A", "C", []string{"B"})
+	_ = dag.AddConditionalEdge("B", "C", []string{"X"})
+
+	var buf bytes.Buffer
+	ast.NoError(dag.WriteDOT(&buf, WithCompleted("A"), WithFrontier("B")))
+	ast.Equal(`digraph dag {
+  "A" [style=filled, fillcolor=palegreen];
+  "B" [style=filled, fillcolor=lightgoldenrod];
+  "C";
+  "A" -> "B";
+  "A" -> "C" [label="B"];
+  "B" -> "C" [label="X", style=dashed, color=gray];
+}
+`, buf.String())
+
+	buf.Reset()
+	ast.NoError(dag.WriteMermaid(&buf, WithCompleted("A"), WithFrontier("B")))
+	ast.Equal(`flowchart TD
+  n0["A"]
+  n1["B"]
+  n2["C"]
+  n0 --> n1
+  n0 -->|"B"| n2
+  n1 -.->|"X"| n2
+  classDef completed fill:#98fb98
+  class n0 completed
+  classDef frontier fill:#fafad2
+  class n1 frontier
+`, buf.String())
+}
+
+func TestReach(t *testing.T) {
+	ast := assert.New(t)
+	dag := newTestDAG()
+
+	ancestors, err := dag.Ancestors("D")
+	ast.NoError(err)
+	ast.Equal([]string{"A", "B", "C"}, ancestors)
+	descendants, err := dag.Descendants("B")
+	ast.NoError(err)
+	ast.Equal([]string{"C", "D", "E"}, descendants)
+	_, err = dag.Descendants("X")
+	ast.Error(err)
+
+	ok, err := dag.IsReachable("A", "E")
+	ast.NoError(err)
+	ast.True(ok)
+	ok, _ = dag.IsReachable("E", "A")
+	ast.False(ok)
+	ok, _ = dag.IsReachable("A", "F")
+	ast.False(ok)
+
+	closure := dag.TransitiveClosure()
+	ast.Equal([]string{"B", "C", "D", "E"}, closure["A"])
+	ast.Empty(closure["F"])
+
+	// 修改图结构后缓存失效
+	_ = dag.AddConditionalEdge("E", "F", nil)
+	descendants, _ = dag.Descendants("B")
+	ast.Equal([]string{"C", "D", "E", "F"}, descendants)
+
+	// C->E 冗余，A->C 是条件边不移除
+	// A 到 D 的其他路径都经过条件边，A->D 不冗余
+	_ = dag.AddConditionalEdge("C", "E", nil)
+	_ = dag.AddConditionalEdge("A", "D", nil)
+	removed := dag.TransitiveReduction()
+	ast.Equal([]EdgeDefinition{{From: "C", To: "E"}}, removed)
+	ast.Contains(dag.Nodes["A"].Children, "D")
+	ast.NotContains(dag.Nodes["C"].Children, "E")
+	ast.Contains(dag.Nodes["A"].Children, "C")
+	ok, _ = dag.IsReachable("C", "E")
+	ast.True(ok)
+}
+
+func TestTypedDAG(t *testing.T) {
+	ast := assert.New(t)
+	type quest struct {
+		Name string
+	}
+	g := NewTypedDAG[int, quest]()
+	ast.True(g.AddNode(1, quest{Name: "序章"}))
+	ast.True(g.AddNode(2, quest{Name: "第一章"}))
+	ast.True(g.AddNode(3, quest{Name: "第二章"}))
+	ast.False(g.AddNode(1, quest{Name: "重复"}))
+
+	v, ok := g.Value(1)
+	ast.True(ok)
+	ast.Equal("序章", v.Name)
+	ast.NoError(g.SetValue(2, quest{Name: "新章节"}))
+	v, _ = g.Value(2)
+	ast.Equal("新章节", v.Name)
+	ast.Error(g.SetValue(9, quest{}))
+
+	ast.NoError(g.AddConditionalEdge(1, 2, nil))
+	ast.NoError(g.AddConditionalEdge(2, 3, []int{4}))
+	ast.Error(g.AddConditionalEdge(3, 1, nil))
+	ast.Equal([]TypedPendingEdge[int]{{From: 2, To: 3, Missing: []int{4}}}, g.PendingEdges())
+
+	reachable, err := g.GetDirectlyReachableNodes(nil)
+	ast.NoError(err)
+	ast.Equal([]int{1, 3}, reachable)
+
+	g.AddNode(4, quest{Name: "支线"})
+	ast.Empty(g.PendingEdges())
+	reachable, _ = g.GetDirectlyReachableNodes([]int{2})
+	ast.Empty(reachable)
+	reachable, _ = g.GetDirectlyReachableNodes([]int{2, 4})
+	ast.Equal([]int{3}, reachable)
+
+	ast.NoError(g.RemoveNode(4))
+	ast.Empty(g.PendingEdges())
+	_, exists := g.Conditions(2, 3)
+	ast.False(exists)
+	ast.Error(g.RemoveEdge(2, 3))
+	ast.Equal([]int{1, 2, 3}, g.NodeIDs())
+
+	// 与 DAG 共用的功能：条件表达式、拓扑序、可达性、传递规约和进度
+	ast.True(g.AddNode(5, quest{Name: "终章"}))
+	ast.NoError(g.AddConditionalEdge(2, 3, nil))
+	ast.NoError(g.AddExprEdge(3, 5, Any(Ref(1), Ref(2))))
+	ast.NoError(g.AddConditionalEdge(1, 3, nil))
+	order, err := g.TopologicalSort()
+	ast.NoError(err)
+	ast.Equal([]int{1, 2, 3, 5}, order)
+	ancestors, err := g.Ancestors(5)
+	ast.NoError(err)
+	ast.Equal([]int{1, 2, 3}, ancestors)
+	ast.Equal([]Edge[int]{{From: 1, To: 3}}, g.TransitiveReduction())
+	p, err := NewTypedProgress(g, 1, 2)
+	ast.NoError(err)
+	ast.Equal([]int{3}, p.Frontier())
+	unlocked, err := p.Complete(3)
+	ast.NoError(err)
+	ast.Equal([]int{5}, unlocked)
+
+	// 默认按插入顺序排列，也可以按 ID 大小排列
+	inserted := NewTypedDAG[int, string]()
+	sorted := NewTypedDAG[int, string](WithOrder(OrderSorted))
+	for _, id := range []int{3, 1, 2} {
+		inserted.AddNode(id, "")
+		sorted.AddNode(id, "")
+	}
+	ast.Equal([]int{3, 1, 2}, inserted.NodeIDs())
+	ast.Equal([]int{1, 2, 3}, sorted.NodeIDs())
+}
+
+func TestCondition(t *testing.T) {
+	ast := assert.New(t)
+	cond, err := ParseCondition("atleast(2, C1, C2, C3) & !(X || Y)")
+	ast.NoError(err)
+	ast.Equal([]string{"C1", "C2", "C3", "X", "Y"}, cond.Nodes())
+	ast.Equal("atleast(2, C1, C2, C3) & !(X | Y)", cond.String())
+	ast.True(cond.Eval(map[string]bool{"C1": true, "C3": true}))
+	ast.False(cond.Eval(map[string]bool{"C1": true}))
+	ast.False(cond.Eval(map[string]bool{"C1": true, "C2": true, "Y": true}))
+
+	again, err := ParseCondition(cond.String())
+	ast.NoError(err)
+	ast.Equal(cond.String(), again.String())
+
+	cond, err = ParseCondition("(A | B) & C | D")
+	ast.NoError(err)
+	ast.Equal("((A | B) & C) | D", cond.String())
+
+	// 包含空白或语法字符的节点 ID 带引号输出，可以重新解析
+	cond = All(Any(Ref("Chapter 1"), Ref("Chapter 2")), Not(Ref(`a"b|c`)), Ref(""), Ref("第三章"))
+	ast.Equal(`("Chapter 1" | "Chapter 2") & !"a\"b|c" & "" & 第三章`, cond.String())
+	again, err = ParseCondition(cond.String())
+	ast.NoError(err)
+	ast.Equal(cond.Nodes(), again.Nodes())
+	ast.Equal(cond.String(), again.String())
+
+	for _, text := range []string{"", "A &", "(A | B", "A B", "atleast(x, A)", "A & )", `"A`, `"A\x"`} {
+		_, err = ParseCondition(text)
+		ast.Error(err, text)
+	}
+
+	dag := NewDAG()
+	for _, id := range []string{"Start", "C1", "C2", "C3", "End"} {
+		dag.AddNode(id)
+	}
+	ast.NoError(dag.AddExprEdge("Start", "End", AtLeast(2, Ref("C1"), Ref("C2"), Ref("C3"))))
+	reachable, err := dag.GetDirectlyReachableNodes([]string{"Start", "C1"})
+	ast.NoError(err)
+	ast.Empty(reachable)
+	reachable, err = dag.GetDirectlyReachableNodes([]string{"Start", "C1", "C3"})
+	ast.NoError(err)
+	ast.Equal([]string{"End"}, reachable)
+
+	cond, ok := dag.EdgeCondition("Start", "End")
+	ast.True(ok)
+	ast.Equal("atleast(2, C1, C2, C3)", cond.String())
+
+	// 条件表达式随配置一起序列化
+	data, err := json.Marshal(dag)
+	ast.NoError(err)
+	loaded, err := LoadJSON(data)
+	ast.NoError(err)
+	reachable, _ = loaded.GetDirectlyReachableNodes([]string{"Start", "C2", "C3"})
+	ast.Equal([]string{"End"}, reachable)
+
+	// 节点 ID 包含空白时同样可以序列化后重新加载
+	quoted := NewDAG()
+	for _, id := range []string{"Start", "Chapter 1", "Chapter 2", "End"} {
+		quoted.AddNode(id)
+	}
+	ast.NoError(quoted.AddExprEdge("Start", "End", Any(Ref("Chapter 1"), Ref("Chapter 2"))))
+	data, err = json.Marshal(quoted)
+	ast.NoError(err)
+	loaded, err = LoadJSON(data)
+	ast.NoError(err)
+	ast.Equal(quoted.Definition(), loaded.Definition())
+	reachable, _ = loaded.GetDirectlyReachableNodes([]string{"Start", "Chapter 2"})
+	ast.Equal([]string{"End"}, reachable)
+
+	// 改为条件列表后表达式失效
+	ast.NoError(dag.AddConditionalEdge("Start", "End", []string{"C1"}))
+	reachable, _ = dag.GetDirectlyReachableNodes([]string{"Start", "C1"})
+	ast.Equal([]string{"End"}, reachable)
+}
+
+func TestProgress(t *testing.T) {
+	ast := assert.New(t)
+	dag := newTestDAG()
+	_, err := NewProgress(dag, "X")
+	ast.Error(err)
+
+	p, err := NewProgress(dag)
+	ast.NoError(err)
+	ast.Equal([]string{"A", "F"}, p.Frontier())
+
+	unlocked, err := p.Complete("A")
+	ast.NoError(err)
+	ast.Equal([]string{"B"}, unlocked)
+	ast.Equal([]string{"B", "F"}, p.Frontier())
+
+	// A->C 的条件 B 满足，C 解锁
+	unlocked, _ = p.Complete("B")
+	ast.Equal([]string{"C"}, unlocked)
+	unlocked, _ = p.Complete("B")
+	ast.Empty(unlocked)
+	ast.True(p.IsUnlocked("C"))
+	ast.False(p.IsUnlocked("D"))
+
+	data, err := p.Snapshot()
+	ast.NoError(err)
+	restored, err := RestoreProgress(dag, data)
+	ast.NoError(err)
+	ast.Equal(p.Completed(), restored.Completed())
+	ast.Equal(p.Frontier(), restored.Frontier())
+
+	// 条件表达式中的 NOT 在条件节点完成后重新锁定
+	g := NewDAG()
+	for _, id := range []string{"A", "B", "X"} {
+		g.AddNode(id)
+	}
+	ast.NoError(g.AddExprEdge("A", "B", Not(Ref("X"))))
+	p, _ = NewProgress(g, "A")
+	ast.True(p.IsUnlocked("B"))
+	_, _ = p.Complete("X")
+	ast.False(p.IsUnlocked("B"))
+}
+
+func TestCriticalPath(t *testing.T) {
+	ast := assert.New(t)
+	dag := NewDAG()
+	for _, id := range []string{"A", "B", "C", "D", "E"} {
+		dag.AddNode(id)
+	}
+	_ = dag.AddConditionalEdge("A", "B", nil)
+	_ = dag.AddConditionalEdge("A", "C", nil)
+	_ = dag.AddConditionalEdge("B", "D", nil)
+	_ = dag.AddConditionalEdge("C", "D", nil)
+
+	_, err := dag.CriticalPath(map[string]float64{"A": -1})
+	ast.Error(err)
+
+	res, err := dag.CriticalPath(map[string]float64{"A": 3, "B": 2, "C": 1, "D": 4, "E": 1})
+	ast.NoError(err)
+	ast.Equal(9.0, res.Length)
+	ast.Equal([]string{"A", "B", "D"}, res.Path)
+	ast.Equal(NodeSchedule{ID: "C", Weight: 1, EarliestStart: 3, EarliestFinish: 4, LatestStart: 4, LatestFinish: 5, Slack: 1}, res.Schedules["C"])
+	ast.True(res.Schedules["B"].Critical())
+	ast.False(res.Schedules["C"].Critical())
+	ast.Equal(8.0, res.Schedules["E"].Slack)
+
+	// "" 是合法的节点 ID，同样出现在关键路径中
+	dag = NewDAG()
+	dag.AddNode("")
+	dag.AddNode("B")
+	_ = dag.AddConditionalEdge("", "B", nil)
+	res, err = dag.CriticalPath(map[string]float64{"": 1, "B": 2})
+	ast.NoError(err)
+	ast.Equal(3.0, res.Length)
+	ast.Equal([]string{"", "B"}, res.Path)
+}
+
+func TestValidate(t *testing.T) {
+	ast := assert.New(t)
+	report := newTestDAG().Validate()
+	ast.Empty(report.Unreachable)
+	ast.Equal([]string{"F"}, report.Isolated)
+
+	dag := NewDAG()
+	for _, id := range []string{"A", "B", "C", "D", "E", "F"} {
+		dag.AddNode(id)
+	}
+	_ = dag.AddConditionalEdge("A", "B", nil)
+	_ = dag.AddConditionalEdge("B", "C", nil)
+	_ = dag.AddConditionalEdge("A", "B", []string{"C"}) // C 是 B 的后代，永远无法满足
+	_ = dag.AddConditionalEdge("A", "D", []string{"X", "A", "A"})
+	for _, id := range []string{"G", "H", "I", "J", "K", "L"} {
+		dag.AddNode(id)
+	}
+	_ = dag.AddConditionalEdge("G", "H", nil)
+	_ = dag.AddConditionalEdge("H", "I", nil)
+	_ = dag.AddConditionalEdge("G", "I", nil) // 与 G->H->I 重复
+	_ = dag.AddConditionalEdge("J", "K", nil)
+	// K 是 J 的后代，H & !H 恒为假，永远无法满足
+	_ = dag.AddExprEdge("G", "J", Any(Ref("K"), All(Ref("H"), Not(Ref("H")))))
+	_ = dag.AddExprEdge("G", "L", Not(Ref("H")))
+
+	report = dag.Validate()
+	ast.False(report.OK())
+	ast.Equal([]string{"B", "C", "D", "J", "K"}, report.Unreachable)
+	ast.Equal([]EdgeIssue{{From: "A", To: "D", Condition: "X"}}, report.MissingConditions)
+	ast.Equal([]EdgeIssue{
+		{From: "A", To: "B", Condition: "C"},
+		{From: "G", To: "J", Condition: "K | (H & !H)"},
+	}, report.NeverFire)
+	ast.Equal([]string{"E", "F"}, report.Isolated)
+	ast.Equal([]EdgeDefinition{{From: "G", To: "I"}}, report.Duplicates)
+	ast.Equal([]EdgeIssue{{From: "A", To: "D", Condition: "A"}}, report.RepeatedConditions)
+	ast.Error(report.Err())
+}
+
+func TestOrder(t *testing.T) {
+	ast := assert.New(t)
+	dag := NewDAG(WithOrder(OrderInsertion))
+	for _, id := range []string{"root", "c", "b", "a"} {
+		dag.AddNode(id)
+	}
+	for _, id := range []string{"c", "b", "a"} {
+		_ = dag.AddConditionalEdge("root", id, nil)
+	}
+	reachable, err := dag.GetDirectlyReachableNodes([]string{"root"})
+	ast.NoError(err)
+	ast.Equal([]string{"c", "b", "a"}, reachable)
+	order, _ := dag.TopologicalSort()
+	ast.Equal([]string{"root", "c", "b", "a"}, order)
+	ast.Equal([]string{"root", "c", "b", "a"}, dag.Definition().Nodes)
+
+	sorted := NewDAG()
+	for _, id := range []string{"c", "b", "a"} {
+		sorted.AddNode(id)
+	}
+	roots, _ := sorted.GetDirectlyReachableNodes(nil)
+	ast.Equal([]string{"a", "b", "c"}, roots)
+}
+
+func TestSafeDAG(t *testing.T) {
+	ast := assert.New(t)
+	s := NewSafeDAG()
+	s.AddNode("root")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("n%d", i)
+			s.AddNode(id)
+			ast.NoError(s.AddConditionalEdge("root", id, nil))
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, _ = s.GetDirectlyReachableNodes([]string{"root"})
+			_, _ = s.Descendants("root")
+			_ = s.Validate()
+			_, _ = s.CriticalPath(nil)
+			// 读锁下并发使用可达性缓存的查询和进度
+			s.Read(func(dag *DAG) {
+				_, _ = dag.Ancestors("root")
+				_ = dag.TransitiveClosure()
+				if p, err := NewProgress(dag, "root"); err == nil {
+					_ = p.Frontier()
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	descendants, err := s.Descendants("root")
+	ast.NoError(err)
+	ast.Len(descendants, 8)
+	ast.True(s.HasNode("n0"))
+}
+
+func TestIncrementalCycleCheck(t *testing.T) {
+	ast := assert.New(t)
+	rnd := rand.New(rand.NewSource(1))
+	dag := NewDAG()
+	const n = 60
+	id := func(i int) string { return fmt.Sprintf("n%d", i) }
+	for i := 0; i < n; i++ {
+		dag.AddNode(id(i))
+	}
+	for i := 0; i < 2000; i++ {
+		from, to := id(rnd.Intn(n)), id(rnd.Intn(n))
+		switch rnd.Intn(10) {
+		case 0:
+			_ = dag.RemoveEdge(from, to)
+		case 1:
+			// 以不存在的节点为条件的等待边同样参与环检测
+			cycle := dag.createsCycle(from, to)
+			ast.Equal(cycle, dag.AddConditionalEdge(from, to, []string{"missing"}) != nil)
+		case 2:
+			_ = dag.RemoveNode(from)
+			dag.AddNode(from)
+		default:
+			cycle := dag.createsCycle(from, to)
+			ast.Equal(cycle, dag.AddConditionalEdge(from, to, nil) != nil)
+		}
+	}
+	_, err := dag.TopologicalSort()
+	ast.NoError(err)
+}
+
+// 比较 createsCycle(DFS) 与增量拓扑序(Pearce–Kelly)两种环检查方式下添加边的开销
+// 正向链上两者都不需要搜索，增量检查多出维护前驱集合的开销
+// BenchmarkAddEdges/reverse-chain/dfs-n=2000            	       2	 798821262 ns/op
+// BenchmarkAddEdges/reverse-chain/pearce-kelly-n=2000   	     176	   6567013 ns/op
+// BenchmarkAddEdges/forward-chain/dfs-n=2000            	     294	   4475836 ns/op
+// BenchmarkAddEdges/forward-chain/pearce-kelly-n=2000   	     175	   6075288 ns/op
+// BenchmarkAddEdges/random/dfs-n=2000                   	      22	  58379862 ns/op
+// BenchmarkAddEdges/random/pearce-kelly-n=2000          	      51	  26680179 ns/op
+func BenchmarkAddEdges(b *testing.B) {
+	const n = 2000
+	id := strconv.Itoa
+	// 三种加边顺序：反向链(DFS 的最坏情况，增量检查无需重排)、正向链(DFS 的最好情况)，
+	// 以及节点顺序被打乱、需要重排拓扑序的随机 DAG
+	workloads := []struct {
+		name  string
+		edges [][2]string
+	}{
+		{name: "reverse-chain"},
+		{name: "forward-chain"},
+		{name: "random"},
+	}
+	for j := n - 2; j >= 0; j-- {
+		workloads[0].edges = append(workloads[0].edges, [2]string{id(j), id(j + 1)})
+	}
+	for j := 0; j < n-1; j++ {
+		workloads[1].edges = append(workloads[1].edges, [2]string{id(j), id(j + 1)})
+	}
+	rnd := rand.New(rand.NewSource(1))
+	perm := rnd.Perm(n)
+	for j := 0; j < 4*n; j++ {
+		a, c := rnd.Intn(n), rnd.Intn(n)
+		if a == c {
+			continue
+		}
+		if a > c {
+			a, c = c, a
+		}
+		workloads[2].edges = append(workloads[2].edges, [2]string{id(perm[a]), id(perm[c])})
+	}
+
+	build := func(b *testing.B, edges [][2]string, add func(dag *DAG, from, to string)) {
+		for i := 0; i < b.N; i++ {
+			dag := NewDAG()
+			for j := 0; j < n; j++ {
+				dag.AddNode(id(j))
+			}
+			for _, edge := range edges {
+				add(dag, edge[0], edge[1])
+			}
+		}
+	}
+	for _, w := range workloads {
+		// 基线用 createsCycle 做环检查，其余的存储与 AddConditionalEdge 相同，只比较环检查的开销
+		b.Run(fmt.Sprintf("%s/dfs-n=%d", w.name, n), func(b *testing.B) {
+			build(b, w.edges, func(dag *DAG, from, to string) {
+				if !dag.createsCycle(from, to) {
+					dag.storeEdge(dag.edgeKey(from, to), from, to, nil)
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("%s/pearce-kelly-n=%d", w.name, n), func(b *testing.B) {
+			build(b, w.edges, func(dag *DAG, from, to string) {
+				_ = dag.AddConditionalEdge(from, to, nil)
+			})
+		})
+	}
+}
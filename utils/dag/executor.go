@@ -0,0 +1,269 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// TaskFunc 节点上挂载的任务
+type TaskFunc func(ctx context.Context) error
+
+// ErrorPolicy 任务失败时的处理策略
+type ErrorPolicy int
+
+const (
+	FailFast        ErrorPolicy = iota // 任意任务失败后取消正在执行的任务，不再启动新任务
+	ContinueOnError                    // 任务失败后只跳过依赖它的节点，其他分支继续执行
+)
+
+// TaskStatus 节点任务的执行状态
+type TaskStatus int
+
+const (
+	TaskPending   TaskStatus = iota // 未执行
+	TaskSucceeded                   // 执行成功
+	TaskFailed                      // 执行失败
+	TaskSkipped                     // 前置节点失败或条件不满足而跳过
+	TaskCanceled                    // 外部 context 取消而未执行，或执行中因取消(包括 FailFast 下其他任务失败)而中断
+)
+
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskPending:
+		return "pending"
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskFailed:
+		return "failed"
+	case TaskSkipped:
+		return "skipped"
+	case TaskCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("TaskStatus(%d)", int(s))
+	}
+}
+
+// NodeResult 单个节点的执行结果
+type NodeResult struct {
+	ID     string
+	Status TaskStatus
+	Err    error
+	Start  time.Time
+	End    time.Time
+}
+
+// Report 一次执行的结果报告
+type Report struct {
+	Results map[string]*NodeResult // 节点 ID 到执行结果的映射
+	dag     *DAG
+}
+
+// IDsWithStatus 返回指定状态的节点，顺序见 WithOrder
+func (r *Report) IDsWithStatus(status TaskStatus) []string {
+	result := []string{}
+	for id, res := range r.Results {
+		if res.Status == status {
+			result = append(result, id)
+		}
+	}
+	if r.dag != nil {
+		r.dag.sortIDs(result)
+	} else {
+		sort.Strings(result)
+	}
+	return result
+}
+
+type execConfig struct {
+	concurrency int
+	policy      ErrorPolicy
+}
+
+// ExecOption 执行器的可选项
+type ExecOption func(*execConfig)
+
+// WithConcurrency 设置同时执行的任务数上限，默认为 CPU 核数
+func WithConcurrency(n int) ExecOption {
+	return func(c *execConfig) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithErrorPolicy 设置任务失败时的处理策略，默认为 FailFast
+func WithErrorPolicy(policy ErrorPolicy) ExecOption {
+	return func(c *execConfig) {
+		c.policy = policy
+	}
+}
+
+// Executor 基于 DAG 的并发任务执行器
+// 没有前置节点的节点最先执行，节点的所有父节点都执行成功且条件边满足后才会执行该节点
+type Executor struct {
+	dag   *DAG
+	tasks map[string]TaskFunc
+	conf  *execConfig
+}
+
+// NewExecutor 创建一个执行器，执行期间不应修改 DAG
+func NewExecutor(dag *DAG, opt ...ExecOption) *Executor {
+	conf := &execConfig{
+		concurrency: runtime.NumCPU(),
+		policy:      FailFast,
+	}
+	for i := range opt {
+		opt[i](conf)
+	}
+	return &Executor{
+		dag:   dag,
+		tasks: make(map[string]TaskFunc),
+		conf:  conf,
+	}
+}
+
+// SetTask 为节点挂载任务，没有挂载任务的节点视为直接成功
+func (e *Executor) SetTask(id string, fn TaskFunc) error {
+	if _, exists := e.dag.Nodes[id]; !exists {
+		return fmt.Errorf("节点 %s 不存在", id)
+	}
+	e.tasks[id] = fn
+	return nil
+}
+
+// Run 执行所有任务，直到全部结束、FailFast 下出现失败或 ctx 被取消
+// 返回值：每个节点的执行结果，以及执行过程中出现的错误(多个错误会合并)
+func (e *Executor) Run(ctx context.Context) (*Report, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	report := &Report{Results: make(map[string]*NodeResult, len(e.dag.Nodes)), dag: e.dag}
+	for id := range e.dag.Nodes {
+		report.Results[id] = &NodeResult{ID: id, Status: TaskPending}
+	}
+	memo := e.dag.reach()
+
+	var (
+		// done 的容量等于同时执行的任务数上限，提前返回时任务也不会阻塞在发送结果上
+		done      = make(chan *NodeResult, e.conf.concurrency)
+		started   = make(map[string]bool, len(e.dag.Nodes))
+		succeeded = make(map[string]bool, len(e.dag.Nodes))
+		remaining = make(map[string]int, len(e.dag.Nodes)) // 节点尚未成功的父节点数量
+		queue     = e.dag.getNodesWithoutPredecessors()
+		running   int
+		stopped   bool
+		errs      []error
+	)
+	for id := range e.dag.Nodes {
+		remaining[id] = len(memo.parents[id])
+	}
+	for _, id := range queue {
+		started[id] = true
+	}
+
+	// ready 检查节点的所有父节点是否都已成功，并且至少有一条入边的条件满足
+	ready := func(id string) bool {
+		if started[id] || remaining[id] > 0 {
+			return false
+		}
+		for _, parentID := range memo.parents[id] {
+			if e.dag.edgeSatisfied(parentID, id, succeeded) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		for !stopped && runCtx.Err() == nil && running < e.conf.concurrency && len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			running++
+			res := report.Results[id]
+			res.Start = time.Now()
+			go e.runTask(runCtx, id, done)
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-done
+		running--
+		result := report.Results[res.ID]
+		result.Status, result.Err, result.End = res.Status, res.Err, res.End
+		if res.Status != TaskSucceeded && runCtx.Err() != nil && errors.Is(res.Err, context.Canceled) {
+			// 因其他任务失败或外部取消而中断的任务，不计入失败
+			result.Status = TaskCanceled
+			continue
+		}
+		if res.Status != TaskSucceeded {
+			errs = append(errs, res.Err)
+			if e.conf.policy == FailFast {
+				stopped = true
+				cancel()
+			}
+			continue
+		}
+
+		// 只检查受影响的节点：子节点，以及以该节点为条件的边的目标节点
+		succeeded[res.ID] = true
+		for childID := range e.dag.Nodes[res.ID].Children {
+			remaining[childID]--
+		}
+		check := func(id string) {
+			if ready(id) {
+				started[id] = true
+				queue = append(queue, id)
+			}
+		}
+		for _, childID := range e.dag.sortedIDs(childSet(e.dag.Nodes[res.ID])) {
+			check(childID)
+		}
+		for _, to := range e.dag.conditionTargets(res.ID) {
+			check(to)
+		}
+	}
+
+	// 标记未执行的节点
+	for _, res := range report.Results {
+		if res.Status != TaskPending {
+			continue
+		}
+		if ctx.Err() != nil {
+			res.Status = TaskCanceled
+		} else {
+			res.Status = TaskSkipped
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return report, errors.Join(errs...)
+}
+
+// runTask 执行单个节点的任务，并将结果发送到 done
+func (e *Executor) runTask(ctx context.Context, id string, done chan<- *NodeResult) {
+	res := &NodeResult{ID: id}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Err = fmt.Errorf("节点 %s 任务 panic: %v", id, r)
+		}
+		res.End = time.Now()
+		if res.Err != nil {
+			res.Status = TaskFailed
+		} else {
+			res.Status = TaskSucceeded
+		}
+		done <- res
+	}()
+	if fn := e.tasks[id]; fn != nil {
+		if err := fn(ctx); err != nil {
+			res.Err = fmt.Errorf("节点 %s 任务失败: %w", id, err)
+		}
+	}
+}
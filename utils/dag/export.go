@@ -0,0 +1,146 @@
+package dag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type exportConfig struct {
+	completed map[string]bool // 高亮为已完成的节点
+	frontier  map[string]bool // 高亮为待解锁的节点
+}
+
+// ExportOption 导出图形时的可选项
+type ExportOption func(*exportConfig)
+
+// WithCompleted 高亮显示已完成的节点
+func WithCompleted(ids ...string) ExportOption {
+	return func(c *exportConfig) {
+		for _, id := range ids {
+			c.completed[id] = true
+		}
+	}
+}
+
+// WithFrontier 高亮显示待解锁(可执行)的节点
+func WithFrontier(ids ...string) ExportOption {
+	return func(c *exportConfig) {
+		for _, id := range ids {
+			c.frontier[id] = true
+		}
+	}
+}
+
+func newExportConfig(opt ...ExportOption) *exportConfig {
+	conf := &exportConfig{
+		completed: make(map[string]bool),
+		frontier:  make(map[string]bool),
+	}
+	for i := range opt {
+		opt[i](conf)
+	}
+	return conf
+}
+
+// isPending 边是否因条件节点不存在而尚未生效
+func (dag *DAG) isPending(from, to string) bool {
+	_, ok := dag.pending[from][to]
+	return ok
+}
+
+// WriteDOT 以 Graphviz DOT 格式导出 DAG
+// 条件边以条件列表作为标签，尚未生效的条件边以虚线表示
+func (dag *DAG) WriteDOT(w io.Writer, opt ...ExportOption) error {
+	conf := newExportConfig(opt...)
+	def := dag.Definition()
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph dag {")
+	for _, id := range def.Nodes {
+		switch {
+		case conf.completed[id]:
+			fmt.Fprintf(bw, "  %q [style=filled, fillcolor=palegreen];\n", id)
+		case conf.frontier[id]:
+			fmt.Fprintf(bw, "  %q [style=filled, fillcolor=lightgoldenrod];\n", id)
+		default:
+			fmt.Fprintf(bw, "  %q;\n", id)
+		}
+	}
+	for _, edge := range def.Edges {
+		var attrs []string
+		if label := edgeLabel(edge); label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", label))
+		}
+		if dag.isPending(edge.From, edge.To) {
+			attrs = append(attrs, "style=dashed", "color=gray")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(bw, "  %q -> %q;\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(bw, "  %q -> %q [%s];\n", edge.From, edge.To, strings.Join(attrs, ", "))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteMermaid 以 Mermaid flowchart 格式导出 DAG
+// 条件边以条件列表作为标签，尚未生效的条件边以虚线表示
+func (dag *DAG) WriteMermaid(w io.Writer, opt ...ExportOption) error {
+	conf := newExportConfig(opt...)
+	def := dag.Definition()
+	bw := bufio.NewWriter(w)
+
+	// Mermaid 的节点 ID 不能包含特殊字符，使用序号代替
+	names := make(map[string]string, len(def.Nodes))
+	fmt.Fprintln(bw, "flowchart TD")
+	for i, id := range def.Nodes {
+		names[id] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(bw, "  %s[\"%s\"]\n", names[id], mermaidEscape(id))
+	}
+	for _, edge := range def.Edges {
+		arrow := "-->"
+		if dag.isPending(edge.From, edge.To) {
+			arrow = "-.->"
+		}
+		if label := edgeLabel(edge); label != "" {
+			fmt.Fprintf(bw, "  %s %s|\"%s\"| %s\n", names[edge.From], arrow, mermaidEscape(label), names[edge.To])
+		} else {
+			fmt.Fprintf(bw, "  %s %s %s\n", names[edge.From], arrow, names[edge.To])
+		}
+	}
+
+	var completed, frontier []string
+	for _, id := range def.Nodes {
+		switch {
+		case conf.completed[id]:
+			completed = append(completed, names[id])
+		case conf.frontier[id]:
+			frontier = append(frontier, names[id])
+		}
+	}
+	if len(completed) > 0 {
+		fmt.Fprintln(bw, "  classDef completed fill:#98fb98")
+		fmt.Fprintf(bw, "  class %s completed\n", strings.Join(completed, ","))
+	}
+	if len(frontier) > 0 {
+		fmt.Fprintln(bw, "  classDef frontier fill:#fafad2")
+		fmt.Fprintf(bw, "  class %s frontier\n", strings.Join(frontier, ","))
+	}
+	return bw.Flush()
+}
+
+// edgeLabel 返回边的条件标签，条件列表以 & 连接
+func edgeLabel(edge EdgeDefinition) string {
+	if edge.Expr != "" {
+		return edge.Expr
+	}
+	return strings.Join(edge.Conditions, " & ")
+}
+
+// mermaidEscape 转义 Mermaid 标签中的双引号
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
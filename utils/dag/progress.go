@@ -0,0 +1,233 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// progressGraph Progress 使用的图查询，由 DAG 与 TypedDAG 共用的实现提供
+type progressGraph[K comparable] interface {
+	hasNode(id K) bool
+	nodeIDs() []K
+	childIDs(id K) []K
+	parentIDs(id K) []K
+	conditionTargets(id K) []K
+	edgeSatisfied(from, to K, completed map[K]bool) bool
+	sortIDs(ids []K)
+}
+
+func (g *graph[K, V, E]) hasNode(id K) bool {
+	_, exists := g.Nodes[id]
+	return exists
+}
+
+func (g *graph[K, V, E]) nodeIDs() []K {
+	result := make([]K, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		result = append(result, id)
+	}
+	return result
+}
+
+func (g *graph[K, V, E]) childIDs(id K) []K {
+	node := g.Nodes[id]
+	result := make([]K, 0, len(node.Children))
+	for childID := range node.Children {
+		result = append(result, childID)
+	}
+	return result
+}
+
+func (g *graph[K, V, E]) parentIDs(id K) []K {
+	return g.reach().parents[id]
+}
+
+// conditionTargets 返回以该节点为条件的边的目标节点
+func (g *graph[K, V, E]) conditionTargets(id K) []K {
+	var result []K
+	for _, key := range g.conditionRefs(id) {
+		to := g.ends[key].To
+		if _, exists := g.Nodes[to]; exists {
+			result = append(result, to)
+		}
+	}
+	return result
+}
+
+// TypedProgress 单个玩家在 DAG 上的进度，维护已完成的节点和已解锁(可执行)的节点
+// 完成节点时只检查受影响的节点，复杂度与节点的度数相关，避免每次调用 GetDirectlyReachableNodes 全量扫描
+// 解锁规则与 GetDirectlyReachableNodes 相同：存在已完成的父节点且对应的边条件满足；
+// 此外没有前置节点的节点始终处于解锁状态，直到被完成
+// TypedProgress 不是线程安全的，使用期间 DAG 结构发生变化时需要调用 Rebuild
+type TypedProgress[K comparable] struct {
+	dag       progressGraph[K]
+	completed map[K]bool
+	frontier  map[K]bool
+}
+
+// Progress DAG 上的进度
+type Progress = TypedProgress[string]
+
+// progressSnapshot Progress 的持久化格式
+type progressSnapshot[K comparable] struct {
+	Completed []K `json:"completed"`
+}
+
+// NewProgress 创建进度，completed 为已完成的节点
+func NewProgress(dag *DAG, completed ...string) (*Progress, error) {
+	return newProgress(&dag.graph, completed)
+}
+
+// NewTypedProgress 创建 TypedDAG 上的进度，completed 为已完成的节点
+func NewTypedProgress[K comparable, V any](g *TypedDAG[K, V], completed ...K) (*TypedProgress[K], error) {
+	return newProgress(&g.graph, completed)
+}
+
+func newProgress[K comparable](dag progressGraph[K], completed []K) (*TypedProgress[K], error) {
+	p := &TypedProgress[K]{
+		dag:       dag,
+		completed: make(map[K]bool, len(completed)),
+	}
+	for _, id := range completed {
+		if !dag.hasNode(id) {
+			return nil, fmt.Errorf("节点 %v 不存在", id)
+		}
+		p.completed[id] = true
+	}
+	p.Rebuild()
+	return p, nil
+}
+
+// RestoreProgress 从 Snapshot 的结果中恢复进度，DAG 中已不存在的节点会被忽略
+func RestoreProgress(dag *DAG, data []byte) (*Progress, error) {
+	p := &Progress{dag: &dag.graph}
+	if err := p.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Rebuild 根据已完成的节点全量重新计算解锁的节点
+func (p *TypedProgress[K]) Rebuild() {
+	p.frontier = make(map[K]bool)
+	for _, id := range p.dag.nodeIDs() {
+		p.refresh(id)
+	}
+}
+
+// unlocked 检查节点是否处于解锁状态
+func (p *TypedProgress[K]) unlocked(id K) bool {
+	parents := p.dag.parentIDs(id)
+	if len(parents) == 0 {
+		return true
+	}
+	for _, parentID := range parents {
+		if p.completed[parentID] && p.dag.edgeSatisfied(parentID, id, p.completed) {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh 重新检查单个节点的解锁状态
+// 返回值：节点是否由未解锁变为解锁
+func (p *TypedProgress[K]) refresh(id K) bool {
+	if p.completed[id] {
+		delete(p.frontier, id)
+		return false
+	}
+	before := p.frontier[id]
+	if p.unlocked(id) {
+		p.frontier[id] = true
+		return !before
+	}
+	delete(p.frontier, id)
+	return false
+}
+
+// Complete 完成一个节点，返回因此新解锁的节点，顺序见 WithOrder
+// 节点已完成时不做任何处理
+func (p *TypedProgress[K]) Complete(id K) ([]K, error) {
+	if !p.dag.hasNode(id) {
+		return nil, fmt.Errorf("节点 %v 不存在", id)
+	}
+	if p.completed[id] {
+		return nil, nil
+	}
+	p.completed[id] = true
+	delete(p.frontier, id)
+
+	// 受影响的节点：子节点，以及以该节点为条件的边的目标节点
+	var unlocked []K
+	check := func(target K) {
+		if p.refresh(target) {
+			unlocked = append(unlocked, target)
+		}
+	}
+	for _, childID := range p.dag.childIDs(id) {
+		check(childID)
+	}
+	for _, to := range p.dag.conditionTargets(id) {
+		check(to)
+	}
+	p.dag.sortIDs(unlocked)
+	return unlocked, nil
+}
+
+// IsCompleted 节点是否已完成
+func (p *TypedProgress[K]) IsCompleted(id K) bool {
+	return p.completed[id]
+}
+
+// IsUnlocked 节点是否已解锁且未完成
+func (p *TypedProgress[K]) IsUnlocked(id K) bool {
+	return p.frontier[id]
+}
+
+// Completed 返回已完成的节点，顺序见 WithOrder
+func (p *TypedProgress[K]) Completed() []K {
+	return p.sortedKeys(p.completed)
+}
+
+// Frontier 返回已解锁且未完成的节点，顺序见 WithOrder
+func (p *TypedProgress[K]) Frontier() []K {
+	return p.sortedKeys(p.frontier)
+}
+
+// Snapshot 将进度序列化为字节，用于持久化
+func (p *TypedProgress[K]) Snapshot() ([]byte, error) {
+	return p.MarshalBinary()
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler，只保存已完成的节点
+func (p *TypedProgress[K]) MarshalBinary() ([]byte, error) {
+	return json.Marshal(progressSnapshot[K]{Completed: p.Completed()})
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler，解锁的节点会重新计算
+func (p *TypedProgress[K]) UnmarshalBinary(data []byte) error {
+	var snapshot progressSnapshot[K]
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	p.completed = make(map[K]bool, len(snapshot.Completed))
+	for _, id := range snapshot.Completed {
+		if p.dag.hasNode(id) {
+			p.completed[id] = true
+		}
+	}
+	p.Rebuild()
+	return nil
+}
+
+// sortedKeys 返回值为 true 的 key，顺序见 WithOrder
+func (p *TypedProgress[K]) sortedKeys(m map[K]bool) []K {
+	result := make([]K, 0, len(m))
+	for id, ok := range m {
+		if ok {
+			result = append(result, id)
+		}
+	}
+	p.dag.sortIDs(result)
+	return result
+}
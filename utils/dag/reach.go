@@ -0,0 +1,227 @@
+package dag
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// reachMemo 可达性查询的缓存
+// 查询时按需填充，内部自行同步，多个查询可以在读锁下并发使用同一个缓存
+type reachMemo[K comparable, E comparable] struct {
+	descendants sync.Map  // 节点 -> 所有后代节点 map[K]struct{}
+	ancestors   sync.Map  // 节点 -> 所有祖先节点 map[K]struct{}
+	parents     map[K][]K // 节点 -> 父节点，创建缓存时计算，之后只读
+	refsOnce    sync.Once
+	conditions  map[K][]E // 条件节点 -> 引用它的边 key
+}
+
+// reachCache 持有当前的可达性缓存，graph 中保存它的指针，整体赋值 DAG 时不会复制原子变量
+type reachCache[K comparable, E comparable] struct {
+	current atomic.Pointer[reachMemo[K, E]]
+}
+
+// reach 返回可达性缓存，图结构变化后会重新创建
+func (g *graph[K, V, E]) reach() *reachMemo[K, E] {
+	if memo := g.memo.current.Load(); memo != nil {
+		return memo
+	}
+	memo := &reachMemo[K, E]{parents: g.parents()}
+	if g.memo.current.CompareAndSwap(nil, memo) {
+		return memo
+	}
+	return g.memo.current.Load()
+}
+
+// resetReach 图结构变化时清空可达性缓存
+func (g *graph[K, V, E]) resetReach() {
+	g.memo.current.Store(nil)
+}
+
+// parents 返回每个节点的父节点列表
+func (g *graph[K, V, E]) parents() map[K][]K {
+	result := make(map[K][]K, len(g.Nodes))
+	for id, node := range g.Nodes {
+		for childID := range node.Children {
+			result[childID] = append(result[childID], id)
+		}
+	}
+	return result
+}
+
+// conditionRefs 返回引用了该条件节点的边，结果会被缓存
+func (g *graph[K, V, E]) conditionRefs(id K) []E {
+	memo := g.reach()
+	memo.refsOnce.Do(func() {
+		memo.conditions = make(map[K][]E)
+		for key, conditions := range g.Edges {
+			for _, condition := range conditions {
+				memo.conditions[condition] = append(memo.conditions[condition], key)
+			}
+		}
+	})
+	return memo.conditions[id]
+}
+
+// descendantSet 返回节点的所有后代节点，结果会被缓存，返回的集合不能修改
+func (g *graph[K, V, E]) descendantSet(id K) map[K]struct{} {
+	memo := g.reach()
+	if set, ok := memo.descendants.Load(id); ok {
+		return set.(map[K]struct{})
+	}
+	set := make(map[K]struct{})
+	for childID := range g.Nodes[id].Children {
+		set[childID] = struct{}{}
+		for d := range g.descendantSet(childID) {
+			set[d] = struct{}{}
+		}
+	}
+	// 并发查询时可能重复计算，结果相同，以先写入的为准
+	actual, _ := memo.descendants.LoadOrStore(id, set)
+	return actual.(map[K]struct{})
+}
+
+// ancestorSet 返回节点的所有祖先节点，结果会被缓存，返回的集合不能修改
+func (g *graph[K, V, E]) ancestorSet(id K) map[K]struct{} {
+	memo := g.reach()
+	if set, ok := memo.ancestors.Load(id); ok {
+		return set.(map[K]struct{})
+	}
+	set := make(map[K]struct{})
+	for _, parentID := range memo.parents[id] {
+		set[parentID] = struct{}{}
+		for a := range g.ancestorSet(parentID) {
+			set[a] = struct{}{}
+		}
+	}
+	actual, _ := memo.ancestors.LoadOrStore(id, set)
+	return actual.(map[K]struct{})
+}
+
+// Ancestors 返回所有能到达该节点的节点，即完成该节点之前需要完成的节点，顺序见 WithOrder
+func (g *graph[K, V, E]) Ancestors(id K) ([]K, error) {
+	if _, exists := g.Nodes[id]; !exists {
+		return nil, fmt.Errorf("节点 %v 不存在", id)
+	}
+	return g.sortedIDs(g.ancestorSet(id)), nil
+}
+
+// Descendants 返回从该节点出发能到达的所有节点，即完成该节点后最终能解锁的节点，顺序见 WithOrder
+func (g *graph[K, V, E]) Descendants(id K) ([]K, error) {
+	if _, exists := g.Nodes[id]; !exists {
+		return nil, fmt.Errorf("节点 %v 不存在", id)
+	}
+	return g.sortedIDs(g.descendantSet(id)), nil
+}
+
+// IsReachable 判断从节点 a 出发是否能到达节点 b
+func (g *graph[K, V, E]) IsReachable(a, b K) (bool, error) {
+	if _, exists := g.Nodes[a]; !exists {
+		return false, fmt.Errorf("节点 %v 不存在", a)
+	}
+	if _, exists := g.Nodes[b]; !exists {
+		return false, fmt.Errorf("节点 %v 不存在", b)
+	}
+	_, ok := g.descendantSet(a)[b]
+	return ok, nil
+}
+
+// TransitiveClosure 返回 DAG 的传递闭包，即每个节点能到达的所有节点
+func (g *graph[K, V, E]) TransitiveClosure() map[K][]K {
+	result := make(map[K][]K, len(g.Nodes))
+	for id := range g.Nodes {
+		result[id] = g.sortedIDs(g.descendantSet(id))
+	}
+	return result
+}
+
+// TransitiveReduction 移除冗余的边并返回被移除的边
+// 边 u->v 冗余是指 u 能够经由其他子节点、只通过无条件边到达 v；
+// 条件边携带额外的条件，不会被移除，也不能用来证明其他边冗余
+func (dag *DAG) TransitiveReduction() []EdgeDefinition {
+	var removed []EdgeDefinition
+	for _, edge := range dag.transitiveReduction() {
+		removed = append(removed, EdgeDefinition{From: edge.From, To: edge.To})
+	}
+	return removed
+}
+
+// transitiveReduction 移除冗余的边并返回被移除的边
+func (g *graph[K, V, E]) transitiveReduction() []Edge[K] {
+	removed := g.redundantEdges()
+	// 移除冗余边不会改变可达性，因此可以在判断完成后统一移除
+	for _, edge := range removed {
+		_ = g.RemoveEdge(edge.From, edge.To)
+	}
+	return removed
+}
+
+// redundantEdges 返回冗余的边，规则见 DAG.TransitiveReduction
+func (g *graph[K, V, E]) redundantEdges() []Edge[K] {
+	var redundant []Edge[K]
+	plain := make(map[K]map[K]struct{}, len(g.Nodes))
+	for _, from := range g.sortedIDs(g.nodeSet()) {
+		node := g.Nodes[from]
+		for _, to := range g.sortedIDs(childSet(node)) {
+			if !g.unconditional(from, to) {
+				continue
+			}
+			for other := range node.Children {
+				if other == to || !g.unconditional(from, other) {
+					continue
+				}
+				if _, ok := g.plainDescendantSet(other, plain)[to]; ok {
+					redundant = append(redundant, Edge[K]{From: from, To: to})
+					break
+				}
+			}
+		}
+	}
+	return redundant
+}
+
+// unconditional 判断边 from->to 是否为没有条件的普通边
+func (g *graph[K, V, E]) unconditional(from, to K) bool {
+	key := g.edgeKey(from, to)
+	if _, exists := g.exprs[key]; exists {
+		return false
+	}
+	return len(g.Edges[key]) == 0
+}
+
+// plainDescendantSet 返回节点只经由无条件边能到达的所有节点，结果缓存在 memo 中
+func (g *graph[K, V, E]) plainDescendantSet(id K, memo map[K]map[K]struct{}) map[K]struct{} {
+	if set, ok := memo[id]; ok {
+		return set
+	}
+	set := make(map[K]struct{})
+	for childID := range g.Nodes[id].Children {
+		if !g.unconditional(id, childID) {
+			continue
+		}
+		set[childID] = struct{}{}
+		for d := range g.plainDescendantSet(childID, memo) {
+			set[d] = struct{}{}
+		}
+	}
+	memo[id] = set
+	return set
+}
+
+// nodeSet 返回所有节点 ID 的集合
+func (g *graph[K, V, E]) nodeSet() map[K]struct{} {
+	set := make(map[K]struct{}, len(g.Nodes))
+	for id := range g.Nodes {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// childSet 返回节点所有子节点 ID 的集合
+func childSet[K comparable, V any](node *TypedNode[K, V]) map[K]struct{} {
+	set := make(map[K]struct{}, len(node.Children))
+	for id := range node.Children {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,173 @@
+package dag
+
+import (
+	"io"
+	"sync"
+)
+
+// SafeDAG 线程安全的 DAG，查询之间可以并发执行，修改操作独占执行
+// Executor 和 Progress 直接持有 *DAG，不受 SafeDAG 的锁保护：
+// 需要在 Read 的 fn 中创建并使用它们(例如在 Read 中执行 Executor.Run，期间的修改会等待执行结束)，
+// 或者保证使用期间不会修改 DAG
+type SafeDAG struct {
+	mu  sync.RWMutex
+	dag *DAG
+}
+
+// NewSafeDAG 创建一个新的空 SafeDAG
+func NewSafeDAG(opt ...DAGOption) *SafeDAG {
+	return &SafeDAG{dag: NewDAG(opt...)}
+}
+
+// WrapDAG 将已有的 DAG 包装为 SafeDAG，之后不应再直接访问原 DAG
+func WrapDAG(dag *DAG) *SafeDAG {
+	return &SafeDAG{dag: dag}
+}
+
+// Read 在读锁保护下访问 DAG，fn 中不能修改 DAG，可以调用 DAG 上的任意查询方法
+func (s *SafeDAG) Read(fn func(dag *DAG)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.dag)
+}
+
+// Write 在写锁保护下访问 DAG，可以在 fn 中批量修改
+func (s *SafeDAG) Write(fn func(dag *DAG) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.dag)
+}
+
+// AddNode 向 DAG 中添加一个节点
+func (s *SafeDAG) AddNode(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dag.AddNode(id)
+}
+
+// AddConditionalEdge 添加一条有条件的从 `from` 节点到 `to` 节点的有向边
+func (s *SafeDAG) AddConditionalEdge(from, to string, conditions []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dag.AddConditionalEdge(from, to, conditions)
+}
+
+// AddExprEdge 添加一条以条件表达式控制的从 `from` 节点到 `to` 节点的有向边
+func (s *SafeDAG) AddExprEdge(from, to string, cond Condition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dag.AddExprEdge(from, to, cond)
+}
+
+// RemoveNode 从 DAG 中移除一个节点
+func (s *SafeDAG) RemoveNode(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dag.RemoveNode(id)
+}
+
+// RemoveEdge 移除从 `from` 节点到 `to` 节点的边
+func (s *SafeDAG) RemoveEdge(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dag.RemoveEdge(from, to)
+}
+
+// HasNode 节点是否存在
+func (s *SafeDAG) HasNode(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.dag.Nodes[id]
+	return exists
+}
+
+// GetDirectlyReachableNodes 返回从给定节点数组出发直接可到达的所有节点，不包括起始节点本身
+func (s *SafeDAG) GetDirectlyReachableNodes(startNodes []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.GetDirectlyReachableNodes(startNodes)
+}
+
+// PendingEdges 返回因条件节点不存在而尚未生效的条件边
+func (s *SafeDAG) PendingEdges() []PendingEdge {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.PendingEdges()
+}
+
+// TopologicalSort 返回 DAG 的拓扑序
+func (s *SafeDAG) TopologicalSort(opt ...SortOption) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.TopologicalSort(opt...)
+}
+
+// Levels 将节点按层分组
+func (s *SafeDAG) Levels(opt ...SortOption) ([][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.Levels(opt...)
+}
+
+// Definition 导出 DAG 的定义
+func (s *SafeDAG) Definition() Definition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.Definition()
+}
+
+// WriteDOT 以 Graphviz DOT 格式导出 DAG
+func (s *SafeDAG) WriteDOT(w io.Writer, opt ...ExportOption) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.WriteDOT(w, opt...)
+}
+
+// WriteMermaid 以 Mermaid flowchart 格式导出 DAG
+func (s *SafeDAG) WriteMermaid(w io.Writer, opt ...ExportOption) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.WriteMermaid(w, opt...)
+}
+
+// Ancestors 返回所有能到达该节点的节点
+func (s *SafeDAG) Ancestors(id string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.Ancestors(id)
+}
+
+// Descendants 返回从该节点出发能到达的所有节点
+func (s *SafeDAG) Descendants(id string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.Descendants(id)
+}
+
+// IsReachable 判断从节点 a 出发是否能到达节点 b
+func (s *SafeDAG) IsReachable(a, b string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.IsReachable(a, b)
+}
+
+// TransitiveClosure 返回 DAG 的传递闭包
+func (s *SafeDAG) TransitiveClosure() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.TransitiveClosure()
+}
+
+// Validate 检查 DAG 中的常见配置问题
+func (s *SafeDAG) Validate() *ValidationReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.Validate()
+}
+
+// CriticalPath 计算关键路径
+func (s *SafeDAG) CriticalPath(weights map[string]float64, opt ...SortOption) (*CriticalPathResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dag.CriticalPath(weights, opt...)
+}
@@ -0,0 +1,224 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition DAG 的配置文件格式，JSON 与 YAML 使用相同的结构：
+//
+//	nodes: [A, B, C]
+//	edges:
+//	  - {from: A, to: B}                   # 普通边
+//	  - {from: B, to: C, conditions: [A]}  # 条件边，所有条件节点完成后才可达
+//	  - {from: C, to: D, expr: "A | B"}     # 条件表达式边，语法见 ParseCondition
+//
+// 加载时会检查重复节点、引用了不存在节点的边(包括条件节点)以及环
+type Definition struct {
+	Nodes []string         `json:"nodes" yaml:"nodes"`
+	Edges []EdgeDefinition `json:"edges,omitempty" yaml:"edges,omitempty"`
+}
+
+// EdgeDefinition 配置文件中的一条边
+type EdgeDefinition struct {
+	From       string   `json:"from" yaml:"from"`
+	To         string   `json:"to" yaml:"to"`
+	Conditions []string `json:"conditions,omitempty" yaml:"conditions,omitempty,flow"`
+	Expr       string   `json:"expr,omitempty" yaml:"expr,omitempty"` // 条件表达式，与 Conditions 互斥
+}
+
+// LoadError 加载配置时的错误，包含出错的行号
+type LoadError struct {
+	Line int
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("第 %d 行: %v", e.Line, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Definition 导出 DAG 的定义，节点和边的顺序见 WithOrder
+// 尚未生效的条件边同样会被导出，其缺失的条件节点会导致再次加载失败
+func (dag *DAG) Definition() Definition {
+	def := Definition{
+		Nodes: make([]string, 0, len(dag.Nodes)),
+		Edges: make([]EdgeDefinition, 0, len(dag.Edges)),
+	}
+	for id := range dag.Nodes {
+		def.Nodes = append(def.Nodes, id)
+	}
+	dag.sortIDs(def.Nodes)
+	for key, conditions := range dag.Edges {
+		ends, ok := dag.ends[key]
+		if !ok {
+			continue
+		}
+		edge := EdgeDefinition{From: ends.From, To: ends.To}
+		if expr, ok := dag.exprs[key]; ok {
+			edge.Expr = expr.String()
+		} else {
+			edge.Conditions = append([]string(nil), conditions...)
+		}
+		def.Edges = append(def.Edges, edge)
+	}
+	sort.Slice(def.Edges, func(i, j int) bool {
+		a, b := def.Edges[i], def.Edges[j]
+		return dag.lessEdge(a.From, a.To, b.From, b.To)
+	})
+	return def
+}
+
+// MarshalJSON 将 DAG 序列化为 Definition 格式的 JSON
+func (dag *DAG) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dag.Definition())
+}
+
+// UnmarshalJSON 从 Definition 格式的 JSON 中加载 DAG，出错时 DAG 保持不变
+func (dag *DAG) UnmarshalJSON(data []byte) error {
+	var check any
+	if err := json.Unmarshal(data, &check); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return &LoadError{Line: lineOfOffset(data, syntaxErr.Offset), Err: err}
+		}
+		return err
+	}
+	// JSON 是 YAML 的子集，借助 yaml.Node 获取行号
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return errors.New("配置为空")
+	}
+	return dag.load(doc.Content[0])
+}
+
+// MarshalYAML 将 DAG 序列化为 Definition 格式的 YAML
+func (dag *DAG) MarshalYAML() (any, error) {
+	return dag.Definition(), nil
+}
+
+// UnmarshalYAML 从 Definition 格式的 YAML 中加载 DAG，出错时 DAG 保持不变
+func (dag *DAG) UnmarshalYAML(value *yaml.Node) error {
+	return dag.load(value)
+}
+
+// LoadJSON 从 JSON 配置中创建 DAG
+func LoadJSON(data []byte) (*DAG, error) {
+	dag := NewDAG()
+	if err := dag.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return dag, nil
+}
+
+// LoadYAML 从 YAML 配置中创建 DAG
+func LoadYAML(data []byte) (*DAG, error) {
+	dag := NewDAG()
+	if err := yaml.Unmarshal(data, dag); err != nil {
+		return nil, err
+	}
+	return dag, nil
+}
+
+// load 解析配置节点，校验通过后替换当前 DAG 的内容
+func (dag *DAG) load(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return &LoadError{Line: value.Line, Err: errors.New("配置必须是对象")}
+	}
+	var nodes, edges *yaml.Node
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		switch value.Content[i].Value {
+		case "nodes":
+			nodes = value.Content[i+1]
+		case "edges":
+			edges = value.Content[i+1]
+		default:
+			return &LoadError{Line: value.Content[i].Line, Err: fmt.Errorf("未知字段 %s", value.Content[i].Value)}
+		}
+	}
+
+	built := NewDAG(WithOrder(dag.order))
+	if nodes != nil {
+		if nodes.Kind != yaml.SequenceNode {
+			return &LoadError{Line: nodes.Line, Err: errors.New("nodes 必须是数组")}
+		}
+		for _, item := range nodes.Content {
+			var id string
+			if err := item.Decode(&id); err != nil {
+				return &LoadError{Line: item.Line, Err: err}
+			}
+			if _, exists := built.Nodes[id]; exists {
+				return &LoadError{Line: item.Line, Err: fmt.Errorf("节点 %s 重复定义", id)}
+			}
+			built.AddNode(id)
+		}
+	}
+	if edges != nil {
+		if edges.Kind != yaml.SequenceNode {
+			return &LoadError{Line: edges.Line, Err: errors.New("edges 必须是数组")}
+		}
+		for _, item := range edges.Content {
+			var edge EdgeDefinition
+			if err := item.Decode(&edge); err != nil {
+				return &LoadError{Line: item.Line, Err: err}
+			}
+			if err := built.addEdgeDefinition(edge); err != nil {
+				return &LoadError{Line: item.Line, Err: err}
+			}
+		}
+	}
+	*dag = *built
+	return nil
+}
+
+// addEdgeDefinition 校验并添加配置中的一条边
+func (dag *DAG) addEdgeDefinition(edge EdgeDefinition) error {
+	var expr Condition
+	if edge.Expr != "" {
+		if len(edge.Conditions) > 0 {
+			return fmt.Errorf("边 %s->%s 不能同时定义 conditions 和 expr", edge.From, edge.To)
+		}
+		var err error
+		if expr, err = ParseCondition(edge.Expr); err != nil {
+			return fmt.Errorf("边 %s->%s: %w", edge.From, edge.To, err)
+		}
+		edge.Conditions = expr.Nodes()
+	}
+	for _, id := range append([]string{edge.From, edge.To}, edge.Conditions...) {
+		if _, exists := dag.Nodes[id]; !exists {
+			return fmt.Errorf("边 %s->%s 引用了不存在的节点 %s", edge.From, edge.To, id)
+		}
+	}
+	if _, exists := dag.Edges[edge.From+"->"+edge.To]; exists {
+		return fmt.Errorf("边 %s->%s 重复定义", edge.From, edge.To)
+	}
+	var err error
+	if expr != nil {
+		err = dag.AddExprEdge(edge.From, edge.To, expr)
+	} else {
+		err = dag.AddConditionalEdge(edge.From, edge.To, edge.Conditions)
+	}
+	if err != nil {
+		return fmt.Errorf("边 %s->%s: %w", edge.From, edge.To, err)
+	}
+	return nil
+}
+
+// lineOfOffset 计算字节偏移量所在的行号
+func lineOfOffset(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,163 @@
+package dag
+
+import (
+	"container/heap"
+	"errors"
+)
+
+// ErrCycle 依赖关系中存在环
+var ErrCycle = errors.New("图中存在环")
+
+type sortConfig struct {
+	conditional bool // 是否考虑条件边
+}
+
+// SortOption 拓扑排序的可选项
+type SortOption func(*sortConfig)
+
+// WithConditionalEdges 排序时考虑 Edges 中记录的条件边：
+// 未连接到 Children 的条件边同样视为依赖，且边的条件节点也视为目标节点的前置节点
+func WithConditionalEdges() SortOption {
+	return func(c *sortConfig) {
+		c.conditional = true
+	}
+}
+
+func newSortConfig(opt ...SortOption) *sortConfig {
+	conf := &sortConfig{}
+	for i := range opt {
+		opt[i](conf)
+	}
+	return conf
+}
+
+// successors 返回每个节点的后继节点集合
+func (g *graph[K, V, E]) successors(conf *sortConfig) map[K]map[K]struct{} {
+	succ := make(map[K]map[K]struct{}, len(g.Nodes))
+	link := func(from, to K) {
+		if _, ok := g.Nodes[from]; !ok {
+			return
+		}
+		if _, ok := g.Nodes[to]; !ok || from == to {
+			return
+		}
+		succ[from][to] = struct{}{}
+	}
+	for id, node := range g.Nodes {
+		succ[id] = make(map[K]struct{}, len(node.Children))
+	}
+	for id, node := range g.Nodes {
+		for childID := range node.Children {
+			link(id, childID)
+		}
+	}
+	if conf.conditional {
+		for key, conditions := range g.Edges {
+			edge, ok := g.ends[key]
+			if !ok {
+				continue
+			}
+			link(edge.From, edge.To)
+			for _, condition := range conditions {
+				link(condition, edge.To)
+			}
+		}
+	}
+	return succ
+}
+
+// idHeap 节点最小堆，用于拓扑排序时按 DAG 设置的顺序打破平局
+type idHeap[K comparable] struct {
+	ids  []K
+	less func(a, b K) bool
+}
+
+func (h *idHeap[K]) Len() int           { return len(h.ids) }
+func (h *idHeap[K]) Less(i, j int) bool { return h.less(h.ids[i], h.ids[j]) }
+func (h *idHeap[K]) Swap(i, j int)      { h.ids[i], h.ids[j] = h.ids[j], h.ids[i] }
+func (h *idHeap[K]) Push(x any)         { h.ids = append(h.ids, x.(K)) }
+func (h *idHeap[K]) Pop() any {
+	n := len(h.ids)
+	x := h.ids[n-1]
+	h.ids = h.ids[:n-1]
+	return x
+}
+
+// inDegrees 计算每个节点的入度
+func inDegrees[K comparable](succ map[K]map[K]struct{}) map[K]int {
+	inDegree := make(map[K]int, len(succ))
+	for id := range succ {
+		inDegree[id] += 0
+		for childID := range succ[id] {
+			inDegree[childID]++
+		}
+	}
+	return inDegree
+}
+
+// TopologicalSort 返回 DAG 的拓扑序
+// 多个节点同时可执行时按 DAG 设置的顺序(见 WithOrder)排列，保证结果稳定
+func (g *graph[K, V, E]) TopologicalSort(opt ...SortOption) ([]K, error) {
+	succ := g.successors(newSortConfig(opt...))
+	inDegree := inDegrees(succ)
+
+	ready := &idHeap[K]{less: g.less}
+	for id, degree := range inDegree {
+		if degree == 0 {
+			ready.ids = append(ready.ids, id)
+		}
+	}
+	heap.Init(ready)
+
+	result := make([]K, 0, len(succ))
+	for ready.Len() > 0 {
+		id := heap.Pop(ready).(K)
+		result = append(result, id)
+		for childID := range succ[id] {
+			inDegree[childID]--
+			if inDegree[childID] == 0 {
+				heap.Push(ready, childID)
+			}
+		}
+	}
+	if len(result) != len(succ) {
+		return nil, ErrCycle
+	}
+	return result, nil
+}
+
+// Levels 将节点按层分组，同一层的节点之间没有依赖，可以并行执行
+// 第 i 层的节点只依赖前 i-1 层的节点，每层内的顺序见 WithOrder
+func (g *graph[K, V, E]) Levels(opt ...SortOption) ([][]K, error) {
+	succ := g.successors(newSortConfig(opt...))
+	inDegree := inDegrees(succ)
+
+	current := []K{}
+	for id, degree := range inDegree {
+		if degree == 0 {
+			current = append(current, id)
+		}
+	}
+
+	var levels [][]K
+	visited := 0
+	for len(current) > 0 {
+		g.sortIDs(current)
+		levels = append(levels, current)
+		visited += len(current)
+		next := []K{}
+		for _, id := range current {
+			for childID := range succ[id] {
+				inDegree[childID]--
+				if inDegree[childID] == 0 {
+					next = append(next, childID)
+				}
+			}
+		}
+		current = next
+	}
+	if visited != len(succ) {
+		return nil, ErrCycle
+	}
+	return levels, nil
+}
@@ -0,0 +1,96 @@
+package dag
+
+import (
+	"fmt"
+)
+
+// TypedNode 表示 TypedDAG 中的一个节点，携带自定义的数据
+type TypedNode[K comparable, V any] struct {
+	ID       K                      // 节点 ID
+	Value    V                      // 节点携带的数据
+	Children map[K]*TypedNode[K, V] // 子节点的映射
+	seq      uint64                 // 节点加入的序号，用于按插入顺序返回结果
+}
+
+// Edge 边的标识，TypedDAG 中 Edges 的 key
+type Edge[K comparable] struct {
+	From K
+	To   K
+}
+
+// TypedDAG 泛型有向无环图，节点 ID 可以是任意可比较的类型，并且每个节点携带一个 V 类型的数据
+// 与 DAG 使用同一套实现，条件边、条件表达式、移除、环检测以及各类查询的语义都相同
+// 查询结果默认按节点的插入顺序返回，K 为字符串或数值类型时可以通过 WithOrder(OrderSorted) 按 ID 大小排列
+// 与 DAG 相同，不要直接修改 Nodes 和 Edges
+type TypedDAG[K comparable, V any] struct {
+	graph[K, V, Edge[K]]
+}
+
+// TypedPendingEdge 因条件节点不存在而尚未生效的条件边
+type TypedPendingEdge[K comparable] struct {
+	From    K   // 源节点
+	To      K   // 目标节点
+	Missing []K // 尚不存在的条件节点
+}
+
+// NewTypedDAG 创建一个新的空 TypedDAG
+func NewTypedDAG[K comparable, V any](opt ...DAGOption) *TypedDAG[K, V] {
+	return &TypedDAG[K, V]{
+		graph: newGraph[K, V](newDAGConfig(OrderInsertion, opt...),
+			func(from, to K) Edge[K] { return Edge[K]{From: from, To: to} },
+			orderedLess[K]()),
+	}
+}
+
+// AddNode 向 DAG 中添加一个节点，节点已存在时不做修改
+// 返回值：是否新增了节点
+func (g *TypedDAG[K, V]) AddNode(id K, value V) bool {
+	return g.addNode(id, value)
+}
+
+// SetValue 修改节点携带的数据
+func (g *TypedDAG[K, V]) SetValue(id K, value V) error {
+	node, exists := g.Nodes[id]
+	if !exists {
+		return fmt.Errorf("节点 %v 不存在", id)
+	}
+	node.Value = value
+	return nil
+}
+
+// Value 返回节点携带的数据
+func (g *TypedDAG[K, V]) Value(id K) (V, bool) {
+	node, exists := g.Nodes[id]
+	if !exists {
+		var zero V
+		return zero, false
+	}
+	return node.Value, true
+}
+
+// Node 返回节点
+func (g *TypedDAG[K, V]) Node(id K) (*TypedNode[K, V], bool) {
+	node, exists := g.Nodes[id]
+	return node, exists
+}
+
+// Len 返回节点数量
+func (g *TypedDAG[K, V]) Len() int {
+	return len(g.Nodes)
+}
+
+// NodeIDs 返回所有节点 ID，顺序见 WithOrder
+func (g *TypedDAG[K, V]) NodeIDs() []K {
+	return g.sortedIDs(g.nodeSet())
+}
+
+// Conditions 返回边的条件节点
+func (g *TypedDAG[K, V]) Conditions(from, to K) ([]K, bool) {
+	conditions, exists := g.Edges[Edge[K]{From: from, To: to}]
+	return conditions, exists
+}
+
+// TransitiveReduction 移除冗余的边并返回被移除的边，规则同 DAG.TransitiveReduction
+func (g *TypedDAG[K, V]) TransitiveReduction() []Edge[K] {
+	return g.transitiveReduction()
+}
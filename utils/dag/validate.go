@@ -0,0 +1,197 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EdgeIssue 边上的问题
+type EdgeIssue struct {
+	From      string // 源节点
+	To        string // 目标节点
+	Condition string // 有问题的条件节点
+}
+
+func (e EdgeIssue) String() string {
+	return fmt.Sprintf("%s->%s(%s)", e.From, e.To, e.Condition)
+}
+
+// ValidationReport DAG 的检查报告，列表的顺序见 WithOrder
+type ValidationReport struct {
+	Unreachable        []string         // 永远无法解锁的节点：所有入边都无法生效，或所有父节点都无法解锁
+	MissingConditions  []EdgeIssue      // 引用了不存在条件节点的边
+	NeverFire          []EdgeIssue      // 永远无法满足的条件边：条件节点是目标节点本身或其后代，或条件表达式不可满足
+	Isolated           []string         // 没有任何边的孤立节点
+	Duplicates         []EdgeDefinition // 重复的无条件边：源节点经由其他无条件边也能到达目标节点，可以用 TransitiveReduction 移除
+	RepeatedConditions []EdgeIssue      // 同一条边上重复的条件节点
+}
+
+// OK 是否没有发现任何问题
+func (r *ValidationReport) OK() bool {
+	return len(r.Unreachable) == 0 && len(r.MissingConditions) == 0 && len(r.NeverFire) == 0 &&
+		len(r.Isolated) == 0 && len(r.Duplicates) == 0 && len(r.RepeatedConditions) == 0
+}
+
+// Err 有问题时返回描述所有问题的错误，否则返回 nil
+func (r *ValidationReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	var lines []string
+	add := func(title string, items []string) {
+		if len(items) > 0 {
+			lines = append(lines, title+": "+strings.Join(items, ", "))
+		}
+	}
+	issues := func(items []EdgeIssue) []string {
+		result := make([]string, len(items))
+		for i := range items {
+			result[i] = items[i].String()
+		}
+		return result
+	}
+	add("无法解锁的节点", r.Unreachable)
+	add("条件节点不存在", issues(r.MissingConditions))
+	add("永远无法满足的条件边", issues(r.NeverFire))
+	add("孤立节点", r.Isolated)
+	duplicates := make([]string, len(r.Duplicates))
+	for i, edge := range r.Duplicates {
+		duplicates[i] = edge.From + "->" + edge.To
+	}
+	add("重复的边", duplicates)
+	add("重复的条件", issues(r.RepeatedConditions))
+	return errors.New(strings.Join(lines, "; "))
+}
+
+// Validate 检查 DAG 中的常见配置问题，可以在测试中对所有配置的图执行
+// 条件表达式边(AddExprEdge)在目标节点及其后代都未完成的前提下检查能否满足
+func (dag *DAG) Validate() *ValidationReport {
+	report := &ValidationReport{
+		Unreachable:        []string{},
+		MissingConditions:  []EdgeIssue{},
+		NeverFire:          []EdgeIssue{},
+		Isolated:           []string{},
+		Duplicates:         []EdgeDefinition{},
+		RepeatedConditions: []EdgeIssue{},
+	}
+
+	keys := make([]string, 0, len(dag.Edges))
+	for key := range dag.Edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := dag.ends[keys[i]], dag.ends[keys[j]]
+		return dag.lessEdge(a.From, a.To, b.From, b.To)
+	})
+
+	hasEdge := make(map[string]bool, len(dag.Nodes))
+	incoming := make(map[string]bool, len(dag.Nodes))
+	dead := make(map[string]bool) // 无法生效的边
+	for _, key := range keys {
+		ends, ok := dag.ends[key]
+		if !ok {
+			continue
+		}
+		from, to := ends.From, ends.To
+		hasEdge[from], hasEdge[to], incoming[to] = true, true, true
+
+		seen := make(map[string]bool)
+		for _, condition := range dag.Edges[key] {
+			if seen[condition] {
+				report.RepeatedConditions = append(report.RepeatedConditions, EdgeIssue{From: from, To: to, Condition: condition})
+				continue
+			}
+			seen[condition] = true
+			if _, exists := dag.Nodes[condition]; !exists {
+				report.MissingConditions = append(report.MissingConditions, EdgeIssue{From: from, To: to, Condition: condition})
+				dead[key] = true
+				continue
+			}
+			if _, isExpr := dag.exprs[key]; isExpr {
+				continue
+			}
+			if _, ok = dag.descendantSet(to)[condition]; ok || condition == to {
+				report.NeverFire = append(report.NeverFire, EdgeIssue{From: from, To: to, Condition: condition})
+				dead[key] = true
+			}
+		}
+		if expr, isExpr := dag.exprs[key]; isExpr && !dead[key] && !dag.satisfiable(expr, to) {
+			report.NeverFire = append(report.NeverFire, EdgeIssue{From: from, To: to, Condition: expr.String()})
+			dead[key] = true
+		}
+	}
+	for _, edge := range dag.redundantEdges() {
+		report.Duplicates = append(report.Duplicates, EdgeDefinition{From: edge.From, To: edge.To})
+	}
+	for _, node := range dag.Nodes {
+		for childID := range node.Children {
+			hasEdge[node.ID], hasEdge[childID], incoming[childID] = true, true, true
+		}
+	}
+
+	// 从没有入边的节点出发，沿能够生效的边传播可解锁状态
+	reachable := make(map[string]bool, len(dag.Nodes))
+	order, err := dag.TopologicalSort()
+	if err != nil {
+		order = dag.sortedIDs(dag.nodeSet())
+	}
+	parents := dag.reach().parents
+	for _, id := range order {
+		if !incoming[id] {
+			reachable[id] = true
+			continue
+		}
+		for _, parentID := range parents[id] {
+			if reachable[parentID] && !dead[parentID+"->"+id] {
+				reachable[id] = true
+				break
+			}
+		}
+	}
+
+	for _, id := range dag.sortedIDs(dag.nodeSet()) {
+		if !hasEdge[id] {
+			report.Isolated = append(report.Isolated, id)
+		}
+		if !reachable[id] {
+			report.Unreachable = append(report.Unreachable, id)
+		}
+	}
+	return report
+}
+
+// maxSatisfiableRefs 可满足性检查穷举的节点数上限
+const maxSatisfiableRefs = 16
+
+// satisfiable 判断条件表达式在目标节点及其后代都未完成的前提下能否满足
+// 穷举其余引用节点的完成情况，引用的节点超过 maxSatisfiableRefs 个时只检查它们全部完成的情况
+func (dag *DAG) satisfiable(expr Condition, to string) bool {
+	impossible := dag.descendantSet(to)
+	var free []string
+	seen := make(map[string]bool)
+	for _, id := range expr.Nodes() {
+		if _, ok := impossible[id]; ok || id == to || seen[id] {
+			continue
+		}
+		seen[id] = true
+		free = append(free, id)
+	}
+	completed := make(map[string]bool, len(free))
+	if len(free) > maxSatisfiableRefs {
+		for _, id := range free {
+			completed[id] = true
+		}
+		return expr.Eval(completed)
+	}
+	for mask := 0; mask < 1<<len(free); mask++ {
+		for i, id := range free {
+			completed[id] = mask&(1<<i) != 0
+		}
+		if expr.Eval(completed) {
+			return true
+		}
+	}
+	return false
+}
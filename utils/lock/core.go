@@ -2,9 +2,13 @@ package lock
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// lockSeq 锁对象的全局序号，用于LockAll时确定稳定的加锁顺序
+var lockSeq uint64
+
 type Locker interface {
 	Acquired(tk Token) bool
 	Lock(opt ...LockOption) Token
@@ -15,26 +19,34 @@ type RWLocker interface {
 	Locker
 	RLock(opt ...LockOption) Token
 	RUnlock(token Token, opt ...UnlockOption) bool
+	Upgrade(readToken Token, opt ...LockOption) Token
+	Downgrade(writeToken Token, opt ...LockOption) Token
 }
 
 // NewLocker 创建新的锁对象
-func NewLocker() Locker {
+func NewLocker(opt ...LockerOption) Locker {
+	conf := newLockerConfig(opt...)
 	return &locker{
+		id:       atomic.AddUint64(&lockSeq, 1),
 		mutex:    sync.Mutex{},
 		write:    0,
 		token:    0,
 		expireAt: time.Time{},
+		clock:    conf.clock,
 	}
 }
 
 // NewRWLocker 创建新的读写锁对象
-func NewRWLocker() RWLocker {
+func NewRWLocker(opt ...LockerOption) RWLocker {
+	conf := newLockerConfig(opt...)
 	return &rwLocker{
+		id:             atomic.AddUint64(&lockSeq, 1),
 		write:          0,
 		writeIntention: 0,
 		mutex:          sync.Mutex{},
 		token:          0,
 		expireAt:       time.Time{},
 		readTokens:     map[Token]time.Time{},
+		clock:          conf.clock,
 	}
 }
@@ -4,14 +4,18 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/NumberMan1/numbox/utils/clock"
 )
 
 // 写锁对象
 type locker struct {
+	id       uint64 // 锁对象的全局序号
 	mutex    sync.Mutex
 	write    int // 使用int而不是bool值的原因，是为了与RWLocker中的read保持类型的一致；
 	token    Token
 	expireAt time.Time
+	clock    clock.Clock // 判断锁是否过期使用的时间源
 	Metrics
 }
 
@@ -23,14 +27,14 @@ func (l *locker) lock(hold time.Duration) Token {
 	defer l.mutex.Unlock()
 
 	// 如果已经被锁定，则返回失败
-	if l.write == 1 && time.Now().Before(l.expireAt) {
+	if l.write == 1 && l.clock.Now().Before(l.expireAt) {
 		return 0
 	}
 
 	// 否则，将写锁数量设置为１，并返回成功
 	l.write = 1
 	l.token++
-	l.expireAt = time.Now().Add(hold)
+	l.expireAt = l.clock.Now().Add(hold)
 	return l.token
 }
 
@@ -66,5 +70,9 @@ func (l *locker) Unlock(token Token, opt ...UnlockOption) bool {
 
 // 是否持有锁
 func (l *locker) Acquired(tk Token) bool {
-	return l.write == 1 && l.token == tk && time.Now().Before(l.expireAt)
+	return l.write == 1 && l.token == tk && l.clock.Now().Before(l.expireAt)
+}
+
+func (l *locker) lockID() uint64 {
+	return l.id
 }
@@ -2,6 +2,7 @@ package lock
 
 import (
 	"fmt"
+	"github.com/NumberMan1/numbox/utils/clock"
 	"github.com/stretchr/testify/assert"
 	"sync"
 	"testing"
@@ -301,6 +302,178 @@ func TestRWLocker(t *testing.T) {
 	})
 }
 
+func TestRWLockerUpgrade(t *testing.T) {
+	t.Run("等待其他读锁释放后升级", func(t *testing.T) {
+		ast := assert.New(t)
+		lk := NewRWLocker()
+		r1 := lk.RLock()
+		r2 := lk.RLock()
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			lk.RUnlock(r2)
+		}()
+		w := lk.Upgrade(r1)
+		ast.NotZero(w)
+		ast.True(lk.Acquired(w))
+		ast.False(lk.Acquired(r1))
+		// 升级后读锁无法再获取
+		ast.Panics(func() {
+			lk.RLock()
+		})
+		ast.True(lk.Unlock(w))
+	})
+	t.Run("升级等待期间阻止新的读锁", func(t *testing.T) {
+		ast := assert.New(t)
+		lk := NewRWLocker()
+		r1 := lk.RLock()
+		r2 := lk.RLock()
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			ast.Panics(func() {
+				lk.RLock(WithAcquireTimeout(time.Millisecond * 50))
+			})
+			lk.RUnlock(r2)
+		}()
+		w := lk.Upgrade(r1)
+		ast.NotZero(w)
+		lk.Unlock(w)
+	})
+	t.Run("无效读锁", func(t *testing.T) {
+		ast := assert.New(t)
+		lk := NewRWLocker()
+		r1 := lk.RLock()
+		lk.RUnlock(r1)
+		ast.Zero(lk.Upgrade(r1))
+	})
+	t.Run("升级超时仍持有读锁", func(t *testing.T) {
+		ast := assert.New(t)
+		lk := NewRWLocker()
+		r1 := lk.RLock()
+		r2 := lk.RLock()
+		ast.Panics(func() {
+			lk.Upgrade(r1)
+		})
+		ast.True(lk.Acquired(r1))
+		lk.RUnlock(r1)
+		lk.RUnlock(r2)
+	})
+	t.Run("降级", func(t *testing.T) {
+		ast := assert.New(t)
+		lk := NewRWLocker()
+		w := lk.Lock()
+		r := lk.Downgrade(w)
+		ast.NotZero(r)
+		ast.False(lk.Acquired(w))
+		ast.True(lk.Acquired(r))
+		ast.Zero(lk.Downgrade(w))
+		// 降级后其他读锁可以进入，写锁不行
+		r2 := lk.RLock()
+		ast.NotZero(r2)
+		ast.Panics(func() {
+			lk.Lock()
+		})
+		lk.RUnlock(r)
+		lk.RUnlock(r2)
+	})
+}
+
+func TestLockAll(t *testing.T) {
+	t.Run("交叉顺序加锁不死锁", func(t *testing.T) {
+		ast := assert.New(t)
+		lk1 := NewLocker()
+		lk2 := NewRWLocker()
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tk := LockAll(lk1, lk2)
+			defer UnlockAll(tk)
+			ast.True(tk.Acquired())
+			time.Sleep(time.Millisecond * 50)
+		}()
+		go func() {
+			defer wg.Done()
+			tk := LockAll(lk2, lk1)
+			defer UnlockAll(tk)
+			ast.True(tk.Acquired())
+			time.Sleep(time.Millisecond * 50)
+		}()
+		wg.Wait()
+	})
+	t.Run("超时回滚", func(t *testing.T) {
+		ast := assert.New(t)
+		lk1 := NewLocker()
+		lk2 := NewLocker()
+		t2 := lk2.Lock()
+		ast.Panics(func() {
+			LockAll(lk1, lk2, lk1)
+		})
+		// lk1已被回滚释放，可以立即获取
+		t1 := lk1.Lock(WithAcquireTimeout(0))
+		ast.NotZero(t1)
+		lk1.Unlock(t1)
+		lk2.Unlock(t2)
+	})
+	t.Run("回调", func(t *testing.T) {
+		ast := assert.New(t)
+		cb := 0
+		tk := LockAllWith([]LockOption{WithLockCallback(func() {
+			cb++
+		})}, NewLocker(), NewLocker())
+		ast.Equal(1, cb)
+		ast.True(UnlockAll(tk, WithUnlockCallback(func() {
+			cb++
+		})))
+		ast.Equal(2, cb)
+		ast.False(tk.Acquired())
+	})
+	t.Run("值类型的外部实现", func(t *testing.T) {
+		ast := assert.New(t)
+		a := valueLocker{NewLocker()}
+		b := valueLocker{NewLocker()}
+		// 顺序与传入位置无关
+		ast.Equal(sortLockers([]Locker{a, b}), sortLockers([]Locker{b, a}))
+		ast.Len(sortLockers([]Locker{a, b, a}), 2)
+		tk := LockAll(b, a)
+		ast.True(tk.Acquired())
+		ast.True(UnlockAll(tk))
+	})
+}
+
+// valueLocker 不提供序号的值类型实现
+type valueLocker struct {
+	Locker
+}
+
+func TestLockerClock(t *testing.T) {
+	ast := assert.New(t)
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	lk := NewLocker(WithClock(fake))
+	tk := lk.Lock(WithLockHoldTimeout(time.Minute))
+	fake.Advance(time.Minute - time.Millisecond)
+	ast.True(lk.Acquired(tk))
+	ast.Panics(func() {
+		lk.Lock(WithAcquireTimeout(0))
+	})
+	fake.Advance(time.Millisecond)
+	ast.False(lk.Acquired(tk))
+	ast.NotZero(lk.Lock(WithAcquireTimeout(0)))
+
+	rw := NewRWLocker(WithClock(fake))
+	rtk := rw.RLock(WithLockHoldTimeout(time.Minute))
+	ast.Panics(func() {
+		rw.Lock(WithAcquireTimeout(0))
+	})
+	fake.Advance(time.Hour)
+	ast.False(rw.Acquired(rtk))
+	ast.False(rw.RUnlock(rtk))
+	wtk := rw.Lock(WithAcquireTimeout(0), WithLockHoldTimeout(time.Minute))
+	ast.True(rw.Acquired(wtk))
+	fake.Advance(time.Minute)
+	ast.False(rw.Acquired(wtk))
+}
+
 // BenchmarkSyncLock-12            51267316                22.93 ns/op            8 B/op          1 allocs/op
 // BenchmarkLock-12                 5301124               236.6 ns/op            48 B/op          1 allocs/op
 // BenchmarkSyncRWLock-12          21413419                54.82 ns/op           24 B/op          1 allocs/op
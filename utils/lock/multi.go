@@ -0,0 +1,152 @@
+package lock
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// identity 能够提供稳定序号的锁，NewLocker/NewRWLocker创建的锁都实现了该接口
+type identity interface {
+	lockID() uint64
+}
+
+// MultiToken LockAll返回的组合锁标识，释放时需交给UnlockAll
+type MultiToken struct {
+	lockers []Locker
+	tokens  []Token
+}
+
+// Acquired 是否仍持有全部的锁
+func (m MultiToken) Acquired() bool {
+	if len(m.lockers) == 0 {
+		return false
+	}
+	for i := range m.lockers {
+		if !m.lockers[i].Acquired(m.tokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// foreignIDs 为非指针实现的外部锁分配的序号，首次参与LockAll时从lockSeq分配，之后保持不变
+// 注意：登记后不会释放，值类型的外部锁数量应当是有限的
+var foreignIDs sync.Map
+
+// lockOrder 锁的排序键：先按类型，再按值
+// kind 0:有序号的锁(NewLocker/NewRWLocker创建或已登记的值类型实现) 1:其他指针实现，按地址
+type lockOrder struct {
+	kind  int
+	value uint64
+}
+
+func orderOf(l Locker) lockOrder {
+	if id, ok := l.(identity); ok {
+		return lockOrder{kind: 0, value: id.lockID()}
+	}
+	v := reflect.ValueOf(l)
+	if v.Kind() == reflect.Ptr {
+		return lockOrder{kind: 1, value: uint64(v.Pointer())}
+	}
+	if !v.Type().Comparable() {
+		panic(fmt.Sprintf("lock: locker type %T has no stable identity", l))
+	}
+	if id, ok := foreignIDs.Load(l); ok {
+		return lockOrder{kind: 0, value: id.(uint64)}
+	}
+	id, _ := foreignIDs.LoadOrStore(l, atomic.AddUint64(&lockSeq, 1))
+	return lockOrder{kind: 0, value: id.(uint64)}
+}
+
+// sortLockers 去重并按稳定的顺序排列锁，保证任意调用方的加锁顺序一致，避免死锁
+func sortLockers(lockers []Locker) []Locker {
+	type item struct {
+		locker Locker
+		order  lockOrder
+	}
+	seen := make(map[lockOrder]struct{}, len(lockers))
+	items := make([]item, 0, len(lockers))
+	for _, l := range lockers {
+		if l == nil {
+			continue
+		}
+		o := orderOf(l)
+		if _, ok := seen[o]; ok {
+			continue
+		}
+		seen[o] = struct{}{}
+		items = append(items, item{locker: l, order: o})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i].order, items[j].order
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.value < b.value
+	})
+	res := make([]Locker, len(items))
+	for i := range items {
+		res[i] = items[i].locker
+	}
+	return res
+}
+
+// LockAll 按稳定的顺序一次性获取多把写锁，使用默认的加锁选项
+// 任意一把锁获取超时时，会释放已经获取到的锁并panic(ErrLockTimeout)，与Lock的行为保持一致
+func LockAll(lockers ...Locker) MultiToken {
+	return LockAllWith(nil, lockers...)
+}
+
+// LockAllWith 同LockAll，可以指定加锁选项
+// 所有锁共享同一个获取超时时间(WithAcquireTimeout)
+// 加锁回调(WithLockCallback)在全部锁获取成功后只执行一次
+func LockAllWith(opt []LockOption, lockers ...Locker) (token MultiToken) {
+	conf := newConfig(opt...)
+	sorted := sortLockers(lockers)
+	deadline := time.Now().Add(conf.acquireTimeout)
+	token.lockers = make([]Locker, 0, len(sorted))
+	token.tokens = make([]Token, 0, len(sorted))
+	defer func() {
+		if err := recover(); err != nil {
+			// 回滚已获取的锁
+			unlockAll(token)
+			panic(err)
+		}
+	}()
+	for _, l := range sorted {
+		tk := l.Lock(WithAcquireTimeout(time.Until(deadline)), WithLockHoldTimeout(conf.lockHoldTimeout))
+		token.lockers = append(token.lockers, l)
+		token.tokens = append(token.tokens, tk)
+	}
+	conf.cb.invoke()
+	return
+}
+
+// UnlockAll 释放LockAll获取的所有锁
+// 返回值：是否所有的锁都释放成功(锁已超时被他人获取时释放会失败)
+func UnlockAll(token MultiToken, opt ...UnlockOption) bool {
+	if len(token.lockers) == 0 {
+		return false
+	}
+	success := unlockAll(token)
+	if success {
+		conf := newUnlockConfig(opt...)
+		conf.cb.invoke()
+	}
+	return success
+}
+
+// unlockAll 按加锁的逆序释放锁
+func unlockAll(token MultiToken) bool {
+	success := true
+	for i := len(token.lockers) - 1; i >= 0; i-- {
+		if !token.lockers[i].Unlock(token.tokens[i]) {
+			success = false
+		}
+	}
+	return success
+}
@@ -1,6 +1,10 @@
 package lock
 
-import "time"
+import (
+	"time"
+
+	"github.com/NumberMan1/numbox/utils/clock"
+)
 
 type callback []func()
 
@@ -14,6 +18,30 @@ func (c *callback) invoke() {
 	}
 }
 
+func newLockerConfig(opt ...LockerOption) *lockerConfig {
+	conf := &lockerConfig{
+		clock: clock.Real,
+	}
+	for i := range opt {
+		opt[i](conf)
+	}
+	return conf
+}
+
+type lockerConfig struct {
+	clock clock.Clock // 判断锁是否过期使用的时间源
+}
+
+type LockerOption func(*lockerConfig)
+
+// WithClock 设置判断锁持有是否过期使用的时间源，测试中可以使用 clock.Fake 手动推进时间
+// 获取锁时的等待超时仍然使用系统时间
+func WithClock(clk clock.Clock) LockerOption {
+	return func(c *lockerConfig) {
+		c.clock = clk
+	}
+}
+
 func newConfig(opt ...LockOption) *lockConfig {
 	conf := &lockConfig{
 		acquireTimeout:  DefaultAcquireTimeout,
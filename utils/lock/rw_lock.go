@@ -4,16 +4,21 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/NumberMan1/numbox/utils/clock"
 )
 
 // 读写锁对象
 type rwLocker struct {
-	write          int   // 使用int而不是bool值的原因，是为了与read保持类型的一致；
-	writeIntention int32 // 写意向
+	id             uint64 // 锁对象的全局序号
+	write          int    // 使用int而不是bool值的原因，是为了与read保持类型的一致；
+	writeIntention int32  // 写意向
 	mutex          sync.Mutex
 	token          Token               // token 计数
 	expireAt       time.Time           // 写锁超时时间
 	readTokens     map[Token]time.Time // 当前持有的所有读锁
+	upgrading      bool                // 是否有读锁正在升级
+	clock          clock.Clock         // 判断锁是否过期使用的时间源
 	Metrics
 }
 
@@ -30,12 +35,12 @@ func (l *rwLocker) lock(hold time.Duration) Token {
 	// 否则，将写锁数量设置为１，并返回成功
 	l.write = 1
 	l.token++
-	l.expireAt = time.Now().Add(hold)
+	l.expireAt = l.clock.Now().Add(hold)
 	return l.token
 }
 
 func (l *rwLocker) refresh() {
-	now := time.Now()
+	now := l.clock.Now()
 	if l.write == 1 && now.After(l.expireAt) {
 		l.write = 0
 		l.readTokens = map[Token]time.Time{}
@@ -94,7 +99,7 @@ func (l *rwLocker) rLock(hold time.Duration) Token {
 		return 0
 	}
 	l.token++
-	l.readTokens[l.token] = time.Now().Add(hold)
+	l.readTokens[l.token] = l.clock.Now().Add(hold)
 
 	return l.token
 }
@@ -119,7 +124,7 @@ func (l *rwLocker) RUnlock(token Token, opt ...UnlockOption) bool {
 	defer l.mutex.Unlock()
 	expireAt, ok := l.readTokens[token]
 	delete(l.readTokens, token)
-	success := ok && time.Now().Before(expireAt)
+	success := ok && l.clock.Now().Before(expireAt)
 	if success {
 		conf := newUnlockConfig(opt...)
 		conf.cb.invoke()
@@ -127,8 +132,87 @@ func (l *rwLocker) RUnlock(token Token, opt ...UnlockOption) bool {
 	return success
 }
 
+// 尝试将读锁升级为写锁
+// 返回值：升级后的写锁token，读锁无效时valid为false
+func (l *rwLocker) upgrade(readToken Token, hold time.Duration) (token Token, valid bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refresh()
+	if _, ok := l.readTokens[readToken]; !ok {
+		return 0, false
+	}
+	// 仍有其他读锁，继续等待
+	if len(l.readTokens) > 1 {
+		return 0, true
+	}
+	delete(l.readTokens, readToken)
+	l.write = 1
+	l.token++
+	l.expireAt = l.clock.Now().Add(hold)
+	return l.token, true
+}
+
+// Upgrade 将读锁升级为写锁
+// 等待期间会阻止新的读锁进入，直到当前读锁成为最后一个读锁时原子的转换为写锁，期间不会有其他写锁插入
+// 返回值：写锁token，读锁无效(已释放或超时)或已有其他读锁在升级时返回0
+// 等待超时则会panic(ErrLockTimeout)，此时仍然持有原读锁
+func (l *rwLocker) Upgrade(readToken Token, opt ...LockOption) (token Token) {
+	l.mutex.Lock()
+	if l.upgrading {
+		// 两个读锁同时升级会互相等待，只允许一个
+		l.mutex.Unlock()
+		return 0
+	}
+	l.upgrading = true
+	l.mutex.Unlock()
+	defer func() {
+		l.mutex.Lock()
+		l.upgrading = false
+		l.mutex.Unlock()
+	}()
+
+	conf := newConfig(opt...)
+	atomic.AddInt32(&l.writeIntention, 1)
+	defer atomic.AddInt32(&l.writeIntention, -1)
+	valid := true
+	isTimeout := withTimeout(conf.acquireTimeout, func() bool {
+		token, valid = l.upgrade(readToken, conf.lockHoldTimeout)
+		return token != 0 || !valid
+	})
+	if isTimeout {
+		atomic.AddInt64(&l.Metrics.RWTimeOutTimes, 1)
+		panic(ErrLockTimeout)
+	}
+	if !valid {
+		return 0
+	}
+	conf.cb.invoke()
+	return
+}
+
+// Downgrade 将写锁降级为读锁，转换过程是原子的，不会有其他写锁插入
+// 返回值：读锁token，写锁无效(已释放或超时)时返回0
+func (l *rwLocker) Downgrade(writeToken Token, opt ...LockOption) Token {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refresh()
+	if l.write == 0 || l.token != writeToken {
+		return 0
+	}
+	conf := newConfig(opt...)
+	l.write = 0
+	l.token++
+	l.readTokens[l.token] = l.clock.Now().Add(conf.lockHoldTimeout)
+	conf.cb.invoke()
+	return l.token
+}
+
 func (l *rwLocker) Acquired(tk Token) bool {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	return l.write == 1 && l.token == tk && l.expireAt.After(time.Now()) || l.readTokens[tk].After(time.Now())
+	return l.write == 1 && l.token == tk && l.expireAt.After(l.clock.Now()) || l.readTokens[tk].After(l.clock.Now())
+}
+
+func (l *rwLocker) lockID() uint64 {
+	return l.id
 }
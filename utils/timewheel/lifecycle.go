@@ -0,0 +1,77 @@
+package timewheel
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+var (
+	ErrStopped = errors.New("timewheel: stopped")         // 时间轮已经停止
+	ErrNilKey  = errors.New("timewheel: task key is nil") // 任务或任务的Key为nil
+)
+
+// DrainMode StopAndDrain 对未执行任务的处理方式。
+type DrainMode int
+
+const (
+	DrainReturn DrainMode = iota // 不执行未执行的任务，将其返回给调用方
+	DrainFire                    // 立即执行所有未执行的任务，重复任务只执行一次
+)
+
+// StopAndDrain 停止时间轮，按 mode 处理所有未执行的任务，并等待已经开始的回调执行完成。
+// DrainReturn 返回的任务按到期时间排序，一次性任务的 Delay 被更新为剩余的延迟时间，可以直接添加到其他时间轮。
+// ctx 结束时不再等待回调，返回 ctx.Err()；时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) StopAndDrain(ctx context.Context, mode DrainMode) ([]*Task, error) {
+	var pending []*Task
+	err := tw.exec(func() {
+		pending = tw.drain()
+		if mode == DrainFire {
+			for _, task := range pending {
+				tw.logRemove(task.Key)
+				if task.Schedule != nil {
+					task.runs++
+				}
+				tw.fire(task)
+			}
+			pending = nil
+		}
+		tw.Stop()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		tw.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return pending, nil
+	case <-ctx.Done():
+		return pending, ctx.Err()
+	}
+}
+
+// drain 从时间轮中取出所有未执行的任务，按到期时间排序。
+func (tw *TimeWheel) drain() []*Task {
+	now := tw.current()
+	var tasks []*Task
+	for _, w := range tw.wheels {
+		for _, l := range w.slots {
+			for e := l.Front(); e != nil; e = e.Next() {
+				task := e.Value.(*Task)
+				if task.Schedule == nil {
+					task.Delay = max(tw.timeOf(task.expire).Sub(now), 0)
+				}
+				tasks = append(tasks, task)
+			}
+			l.Init()
+		}
+	}
+	clear(tw.timer)
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].expire < tasks[j].expire })
+	return tasks
+}
@@ -0,0 +1,56 @@
+package timewheel
+
+// addPeriodic 计算重复任务的首次执行时间，并将其放入时间轮。
+func (tw *TimeWheel) addPeriodic(task *Task) {
+	task.runs = 0
+	from := tw.current()
+	if !task.StartAt.IsZero() && (task.CatchUp || task.StartAt.After(from)) {
+		from = task.StartAt
+	}
+	task.next = task.Schedule.Next(from)
+	tw.schedule(task)
+}
+
+// finished 重复任务是否已经不需要再执行。
+func (task *Task) finished() bool {
+	return task.next.IsZero() ||
+		(task.MaxRuns > 0 && task.runs >= task.MaxRuns) ||
+		(!task.EndAt.IsZero() && task.next.After(task.EndAt))
+}
+
+// schedule 将重复任务按下一次执行时间放入时间轮，任务已经结束时不做任何操作。
+func (tw *TimeWheel) schedule(task *Task) {
+	if task.finished() {
+		return
+	}
+	task.expire = tw.tickOf(task.next)
+	tw.place(task)
+}
+
+// runPeriodic 执行到期的重复任务，并安排下一次执行。
+// 需要补上错过的执行时，计划时间已经过去的执行会在本次滴答中依次执行。
+func (tw *TimeWheel) runPeriodic(task *Task) {
+	now := tw.now().Add(tw.interval)
+	for {
+		task.runs++
+		// 回调与时间轮并发执行，传入副本以免读到之后的修改
+		run := *task
+		tw.fire(&run)
+
+		task.next = task.Schedule.Next(task.next)
+		if !task.CatchUp {
+			for !task.next.IsZero() && !task.next.After(now) {
+				task.next = task.Schedule.Next(task.next)
+			}
+		}
+		if task.finished() || task.next.After(now) {
+			break
+		}
+	}
+	tw.schedule(task)
+	if task.finished() {
+		tw.logRemove(task.Key)
+	} else {
+		tw.logAdd(task)
+	}
+}
@@ -0,0 +1,254 @@
+package timewheel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Codec 持久化时任务 Key 和 Data 的编解码器。
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte) (any, error)
+}
+
+// GobCodec 使用 encoding/gob 编解码，是默认的编解码器。
+// 基础类型可以直接使用，自定义类型需要先调用 gob.Register 注册。
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte) (any, error) {
+	var v any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// 持久化记录的操作类型
+const (
+	opAdd    = "add"
+	opRemove = "remove"
+)
+
+// record 快照和操作日志中的一条记录，每条记录占一行 JSON。
+type record struct {
+	Op       string    `json:"op"`
+	Key      []byte    `json:"key"`
+	Data     []byte    `json:"data,omitempty"`
+	Due      time.Time `json:"due,omitzero"`       // 到期时间，重复任务为下一次执行的计划时间
+	Schedule string    `json:"schedule,omitempty"` // 重复任务的执行计划，见 ParseCron
+	MaxRuns  int       `json:"max_runs,omitempty"`
+	StartAt  time.Time `json:"start_at,omitzero"`
+	EndAt    time.Time `json:"end_at,omitzero"`
+	CatchUp  bool      `json:"catch_up,omitempty"`
+	Runs     int       `json:"runs,omitempty"`
+}
+
+// WithCodec 设置持久化任务 Key 和 Data 使用的编解码器，默认为 GobCodec。
+func WithCodec(codec Codec) Option {
+	return func(tw *TimeWheel) {
+		tw.codec = codec
+	}
+}
+
+// WithJournal 将任务的添加、移除和执行以追加的方式记录到 w 中。
+// 重启时依次将快照和之后的日志传给 Restore 即可恢复未执行的任务，见 Snapshot。
+func WithJournal(w io.Writer) Option {
+	return func(tw *TimeWheel) {
+		tw.journal = w
+	}
+}
+
+// encodeTask 将待执行的任务编码为记录。
+func (tw *TimeWheel) encodeTask(task *Task) (*record, error) {
+	key, err := tw.codec.Marshal(task.Key)
+	if err != nil {
+		return nil, fmt.Errorf("encode key %v: %w", task.Key, err)
+	}
+	rec := &record{Op: opAdd, Key: key, Due: task.next}
+	if task.Data != nil {
+		if rec.Data, err = tw.codec.Marshal(task.Data); err != nil {
+			return nil, fmt.Errorf("encode data of task %v: %w", task.Key, err)
+		}
+	}
+	if task.Schedule != nil {
+		// 只有 ParseCron 能够解析的执行计划才能在 Restore 时恢复
+		schedule, ok := task.Schedule.(fmt.Stringer)
+		if !ok {
+			return nil, fmt.Errorf("schedule of task %v is not serializable", task.Key)
+		}
+		if _, err = ParseCron(schedule.String()); err != nil {
+			return nil, fmt.Errorf("schedule of task %v is not serializable: %w", task.Key, err)
+		}
+		rec.Due, rec.Schedule, rec.Runs = task.next, schedule.String(), task.runs
+		rec.MaxRuns, rec.StartAt, rec.EndAt, rec.CatchUp = task.MaxRuns, task.StartAt, task.EndAt, task.CatchUp
+	}
+	return rec, nil
+}
+
+// decodeTask 将记录解码为任务。
+func (tw *TimeWheel) decodeTask(rec *record) (*Task, error) {
+	key, err := tw.codec.Unmarshal(rec.Key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	task := &Task{Key: key, next: rec.Due}
+	if rec.Data != nil {
+		if task.Data, err = tw.codec.Unmarshal(rec.Data); err != nil {
+			return nil, fmt.Errorf("decode data of task %v: %w", key, err)
+		}
+	}
+	if rec.Schedule != "" {
+		if task.Schedule, err = ParseCron(rec.Schedule); err != nil {
+			return nil, fmt.Errorf("decode schedule of task %v: %w", key, err)
+		}
+		task.runs, task.MaxRuns, task.StartAt, task.EndAt, task.CatchUp = rec.Runs, rec.MaxRuns, rec.StartAt, rec.EndAt, rec.CatchUp
+	}
+	return task, nil
+}
+
+// writeRecord 写入一行记录。
+func writeRecord(w io.Writer, rec *record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// logAdd 在操作日志中记录任务的添加，写入失败的错误在下一次 Snapshot 时返回。
+func (tw *TimeWheel) logAdd(task *Task) {
+	if tw.journal == nil {
+		return
+	}
+	rec, err := tw.encodeTask(task)
+	if err == nil {
+		err = writeRecord(tw.journal, rec)
+	}
+	tw.journalErr = errors.Join(tw.journalErr, err)
+}
+
+// logRemove 在操作日志中记录任务的移除或执行完成。
+func (tw *TimeWheel) logRemove(key any) {
+	if tw.journal == nil {
+		return
+	}
+	data, err := tw.codec.Marshal(key)
+	if err == nil {
+		err = writeRecord(tw.journal, &record{Op: opRemove, Key: data})
+	}
+	tw.journalErr = errors.Join(tw.journalErr, err)
+}
+
+// Snapshot 将所有待执行的任务连同到期时间写入 w，之后可以通过 Restore 恢复。
+// journal 不为 nil 时，之后的操作日志改为写入 journal，旧的日志可以丢弃；
+// 快照与日志切换之间不会有其他操作，重启时只需要恢复新的快照和新的日志。
+// 任务的 Callback 不会被持久化，恢复的任务到期时通过 OnExpired 处理。
+// 返回值包括此前写入操作日志时出现的错误，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) Snapshot(w io.Writer, journal io.Writer) (err error) {
+	execErr := tw.exec(func() {
+		err, tw.journalErr = tw.journalErr, nil
+		for _, wh := range tw.wheels {
+			for _, l := range wh.slots {
+				for e := l.Front(); e != nil; e = e.Next() {
+					rec, encodeErr := tw.encodeTask(e.Value.(*Task))
+					if encodeErr == nil {
+						encodeErr = writeRecord(w, rec)
+					}
+					if encodeErr != nil {
+						err = errors.Join(err, encodeErr)
+					}
+				}
+			}
+		}
+		if journal != nil {
+			tw.journal = journal
+		}
+	})
+	if execErr != nil {
+		return execErr
+	}
+	return
+}
+
+// Restore 依次读取快照和操作日志，恢复其中未执行的任务，时间轮需要已经启动。
+// 已经过期的任务在下一次滴答时执行，重复任务的执行计划见 Task.CatchUp。
+// 存在相同 Key 的任务时，恢复的任务会替换已有的任务。
+// 无法解析的记录会被跳过，其余的任务照常恢复，返回值合并了所有被跳过记录的错误。
+func (tw *TimeWheel) Restore(readers ...io.Reader) error {
+	var (
+		keys []string
+		errs []error
+	)
+	pending := make(map[string]*record)
+	for i, r := range readers {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 16<<20)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			rec := &record{}
+			if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+				errs = append(errs, fmt.Errorf("reader %d line %d: %w", i, line, err))
+				continue
+			}
+			key := string(rec.Key)
+			switch rec.Op {
+			case opAdd:
+				if _, exists := pending[key]; !exists {
+					keys = append(keys, key)
+				}
+				pending[key] = rec
+			case opRemove:
+				delete(pending, key)
+			default:
+				errs = append(errs, fmt.Errorf("reader %d line %d: unknown op %q", i, line, rec.Op))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("reader %d: %w", i, err))
+		}
+	}
+
+	tasks := make([]*Task, 0, len(pending))
+	for _, key := range keys {
+		if rec, exists := pending[key]; exists {
+			task, err := tw.decodeTask(rec)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	err := tw.exec(func() {
+		for _, task := range tasks {
+			tw.detach(task.Key)
+			if task.Schedule != nil {
+				tw.schedule(task)
+			} else {
+				task.expire = tw.tickOf(task.next)
+				tw.place(task)
+			}
+			if _, exists := tw.timer[task.Key]; exists {
+				tw.logAdd(task)
+			}
+		}
+	})
+	return errors.Join(append(errs, err)...)
+}
@@ -0,0 +1,116 @@
+package timewheel
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrQueueFull 回调队列已满，任务的回调被丢弃。
+var ErrQueueFull = errors.New("timewheel: callback queue is full")
+
+// Backpressure 回调队列已满时的处理策略。
+type Backpressure int
+
+const (
+	BackpressureBlock      Backpressure = iota // 等待队列空出位置，期间时间轮暂停转动
+	BackpressureCallerRuns                     // 在时间轮的 goroutine 中直接执行回调
+	BackpressureDrop                           // 丢弃回调，并以 ErrQueueFull 调用错误处理函数
+)
+
+// WithWorkerPool 使用固定数量的 worker 执行回调，到期的任务先放入长度为 queueSize 的队列，
+// 队列已满时按 policy 处理。默认每个到期的任务都会启动一个新的 goroutine 执行回调。
+func WithWorkerPool(workers, queueSize int, policy Backpressure) Option {
+	return func(tw *TimeWheel) {
+		if workers > 0 && queueSize >= 0 {
+			tw.workers, tw.queueSize, tw.backpressure = workers, queueSize, policy
+		}
+	}
+}
+
+// WithInlineCallbacks 在时间轮的 goroutine 中依次执行回调，回调必须足够快，否则会推迟之后的任务。
+func WithInlineCallbacks() Option {
+	return func(tw *TimeWheel) {
+		tw.inline = true
+	}
+}
+
+// WithErrorHandler 设置回调 panic 或被丢弃时的处理函数，处理函数可能被并发调用。
+// 无论是否设置，回调的 panic 都会被恢复，不会导致进程崩溃。
+func WithErrorHandler(fn func(task *Task, err error)) Option {
+	return func(tw *TimeWheel) {
+		tw.onError = fn
+	}
+}
+
+// startWorkers 启动执行回调的 worker。
+func (tw *TimeWheel) startWorkers() {
+	if tw.inline || tw.workers <= 0 {
+		return
+	}
+	queue := make(chan *Task, tw.queueSize)
+	tw.queue = queue
+	for i := 0; i < tw.workers; i++ {
+		go func() {
+			for task := range queue {
+				tw.invoke(task)
+			}
+		}()
+	}
+}
+
+// stopWorkers 关闭回调队列，worker 执行完队列中剩余的回调后退出。
+func (tw *TimeWheel) stopWorkers() {
+	if tw.queue != nil {
+		close(tw.queue)
+		tw.queue = nil
+	}
+}
+
+// fire 执行任务的回调。默认使用 goroutine 以防止回调阻塞时间轮，见 WithWorkerPool 和 WithInlineCallbacks。
+func (tw *TimeWheel) fire(task *Task) {
+	tw.running.Add(1)
+	switch {
+	case tw.inline:
+		tw.invoke(task)
+	case tw.queue == nil:
+		go tw.invoke(task)
+	default:
+		select {
+		case tw.queue <- task:
+			return
+		default:
+		}
+		switch tw.backpressure {
+		case BackpressureCallerRuns:
+			tw.invoke(task)
+		case BackpressureDrop:
+			tw.running.Done()
+			tw.handleError(task, ErrQueueFull)
+		default:
+			tw.queue <- task
+		}
+	}
+}
+
+// invoke 执行回调，并恢复回调中的 panic。
+func (tw *TimeWheel) invoke(task *Task) {
+	defer tw.running.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			tw.handleError(task, fmt.Errorf("timewheel: callback of task %v panic: %v\n%s", task.Key, r, debug.Stack()))
+		}
+	}()
+	if tw.OnExpired != nil {
+		tw.OnExpired(task)
+	} else if task.Callback != nil {
+		task.Callback(task.Data)
+	}
+}
+
+// handleError 调用错误处理函数。
+func (tw *TimeWheel) handleError(task *Task, err error) {
+	if tw.onError != nil {
+		tw.onError(task, err)
+	}
+}
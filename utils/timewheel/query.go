@@ -0,0 +1,92 @@
+package timewheel
+
+import (
+	"errors"
+	"iter"
+	"sort"
+	"time"
+)
+
+// ErrTaskNotFound 任务不存在或已经执行。
+var ErrTaskNotFound = errors.New("timewheel: task not found")
+
+// Reschedule 将未执行的任务改为从现在起 delay 之后执行，重复任务之后的执行从新的时间开始计算。
+// 任务不存在时返回 ErrTaskNotFound，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) Reschedule(key any, delay time.Duration) error {
+	found := false
+	err := tw.exec(func() {
+		task := tw.detach(key)
+		if task == nil {
+			return
+		}
+		found = true
+		task.next = tw.current().Add(delay)
+		task.expire = tw.tickOf(task.next)
+		tw.place(task)
+		tw.logAdd(task)
+	})
+	if err == nil && !found {
+		err = ErrTaskNotFound
+	}
+	return err
+}
+
+// Remaining 返回任务距离执行还剩的时间。
+// 任务不存在时返回 ErrTaskNotFound，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) Remaining(key any) (remaining time.Duration, err error) {
+	found := false
+	err = tw.exec(func() {
+		if e, exists := tw.timer[key]; exists {
+			remaining, found = tw.timeOf(e.Value.(*Task).expire).Sub(tw.current()), true
+		}
+	})
+	if err == nil && !found {
+		err = ErrTaskNotFound
+	}
+	return
+}
+
+// Exists 任务是否存在且尚未执行，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) Exists(key any) (ok bool, err error) {
+	err = tw.exec(func() {
+		_, ok = tw.timer[key]
+	})
+	return
+}
+
+// Len 返回未执行的任务数量，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) Len() (n int, err error) {
+	err = tw.exec(func() {
+		n = len(tw.timer)
+	})
+	return
+}
+
+// Tasks 返回未执行任务的迭代器，按执行时间排序，同时给出距离执行还剩的时间。
+// 迭代的是调用时任务的副本，迭代期间可以调用时间轮的其他方法。时间轮已经停止时迭代器为空。
+func (tw *TimeWheel) Tasks() iter.Seq2[*Task, time.Duration] {
+	var tasks []*Task
+	var remaining []time.Duration
+	tw.exec(func() {
+		now := tw.current()
+		for _, w := range tw.wheels {
+			for _, l := range w.slots {
+				for e := l.Front(); e != nil; e = e.Next() {
+					task := *e.Value.(*Task)
+					tasks = append(tasks, &task)
+				}
+			}
+		}
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].expire < tasks[j].expire })
+		for _, task := range tasks {
+			remaining = append(remaining, tw.timeOf(task.expire).Sub(now))
+		}
+	})
+	return func(yield func(*Task, time.Duration) bool) {
+		for i := range tasks {
+			if !yield(tasks[i], remaining[i]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package timewheel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 重复任务的执行计划。
+type Schedule interface {
+	// Next 返回晚于 t 的下一次执行时间，返回零值表示不再执行。
+	Next(t time.Time) time.Time
+}
+
+// every 固定间隔的执行计划。
+type every time.Duration
+
+// Every 返回每隔 d 执行一次的计划，d 必须为正数。
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+// String 返回 "@every 间隔" 形式的描述，可以由 ParseCron 解析。
+func (e every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+func (e every) Next(t time.Time) time.Time {
+	if e <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule 由 cron 表达式描述的执行计划，每个字段用位集合表示允许的取值。
+type cronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // 日期和星期字段是否为 *
+}
+
+// cronField cron 表达式中一个字段的取值范围。
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron 解析标准的 5 段 cron 表达式：分 时 日 月 星期。
+// 每段支持 *、数字、范围 a-b、步长 */n 或 a-b/n，以及用逗号分隔的列表，星期中的 0 和 7 都表示周日。
+// 日和星期都不为 * 时，满足其一即可执行。执行时间按传入 Next 的时间所在的时区计算。
+// 例如 "0 5 * * *" 表示每天 05:00，"*/15 9-18 * * 1-5" 表示工作日 9 点到 18 点每 15 分钟。
+// 也可以使用 "@every 1h30m" 表示固定间隔，与 Every 相同。
+func ParseCron(expr string) (Schedule, error) {
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval in %q", expr)
+		}
+		return Every(interval), nil
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expr, len(cronFields))
+	}
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	// 星期中的 7 与 0 等价
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		expr:    expr,
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField 解析 cron 表达式的一个字段。
+func parseCronField(part string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(part, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", item, field.name)
+			}
+			step = n
+		}
+		lo, hi := field.min, field.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s", item, field.name)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s", item, field.name)
+				}
+			} else if hasStep {
+				hi = field.max
+			}
+		}
+		if lo < field.min || hi > field.max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range [%d, %d] in %s", item, field.min, field.max, field.name)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSchedule) String() string {
+	return c.expr
+}
+
+// dayMatches 日期是否满足日和星期字段。
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// 从下一个整分钟开始逐字段查找，最多查找 5 年
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<m) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -3,96 +3,229 @@ package timewheel
 import (
 	"container/list"
 	"fmt"
+	"io"
+	"sync"
 	"time"
+
+	"github.com/NumberMan1/numbox/utils/clock"
 )
 
 // Task 是时间轮中的一个定时任务。
+// 设置了 Schedule 的任务会按计划重复执行，直到达到执行次数上限、超过结束时间或被 RemoveTask 取消。
 type Task struct {
-	Delay    time.Duration // 任务的延迟时间
+	Delay    time.Duration // 任务的延迟时间，重复任务忽略此字段
 	Key      any           // 任务的唯一标识符，用于取消任务
 	Data     any           // 任务携带的自定义数据
 	Callback func(any)     // 任务到期时执行的回调函数
-	circle   int           // 任务需要在时间轮上转动的圈数
+	Schedule Schedule      // 重复任务的执行计划，为 nil 时任务只执行一次
+	MaxRuns  int           // 重复任务的最大执行次数，0 表示不限制
+	StartAt  time.Time     // 重复任务的开始时间，首次执行时间晚于此时间，零值表示从添加时开始
+	EndAt    time.Time     // 重复任务的结束时间，晚于此时间的执行被丢弃，零值表示不限制
+	CatchUp  bool          // 是否补上错过的执行（包括 StartAt 之后已经过去的执行），默认跳过
+	expire   int64         // 任务到期的滴答序号
+	runs     int           // 重复任务已经执行的次数
+	next     time.Time     // 任务本次执行的计划时间
+	slot     *list.List    // 任务所在的槽位
+}
+
+// Runs 返回重复任务包括本次在内已经执行的次数。
+func (t *Task) Runs() int {
+	return t.runs
+}
+
+// ScheduledAt 返回任务本次执行的计划时间，实际执行时间见 WithRounding。
+func (t *Task) ScheduledAt() time.Time {
+	return t.next
+}
+
+// addRequest 添加任务的请求，at 为 AddTaskAt 指定的执行时间，零值表示按 Delay 计算。
+type addRequest struct {
+	task *Task
+	at   time.Time
+}
+
+// wheel 多层时间轮中的一层。
+type wheel struct {
+	slots []*list.List // 该层的槽位
+	unit  int64        // 该层每个槽位代表的滴答数
 }
 
 // TimeWheel 是一个时间轮调度器。
 // 它将时间划分为多个槽（slot），每个槽代表一个时间间隔。
 // 定时任务根据其到期时间被放入相应的槽中。
 // 调度器通过一个指针按固定间隔扫过所有槽，来触发到期的任务。
+// 通过 WithLevels 可以在其上叠加多层时间轮（如秒、分、时、天），较长的任务先放在高层，
+// 随着时间推进逐层下沉，每次滴答只需要处理真正到期的任务。
 // 此实现是线程安全的。所有操作通过内部 channel 在单一 goroutine 中完成。
 type TimeWheel struct {
-	interval   time.Duration    // 每个槽代表的时间间隔
-	ticker     *time.Ticker     // Go原生的定时器，用于驱动时间轮指针移动
-	slots      []*list.List     // 时间轮的槽位，每个槽是一个双向链表，存储任务
-	timer      map[any]int      // 任务Key到其所在槽索引的映射，用于快速删除任务
-	currentPos int              // 指针当前所在的槽位索引
-	slotNum    int              // 槽位的总数
-	taskChan   chan *Task       // 用于添加任务的channel
-	removeChan chan any         // 用于移除任务的channel
-	stopChan   chan struct{}    // 用于停止时间轮的channel
-	OnExpired  func(task *Task) // 任务到期时的统一回调
+	interval   time.Duration         // 每个槽代表的时间间隔
+	clock      clock.Clock           // 时间源，默认为系统时间
+	ticker     clock.Ticker          // 定时器，用于驱动时间轮指针移动
+	wheels     []*wheel              // 各层时间轮，第0层的槽位代表一个滴答，每个槽是一个双向链表，存储任务
+	timer      map[any]*list.Element // 任务Key到其在槽位链表中元素的映射，用于在常数时间内删除任务
+	ticks      int64                 // 已经处理的滴答数，第0层指针当前所在的槽位为 ticks % 槽位数
+	start      time.Time             // 时间轮启动的时间，第 n 次滴答对应的时间为 start + (n+1)*interval
+	taskChan   chan addRequest       // 用于添加任务的channel
+	removeChan chan any              // 用于移除任务的channel
+	execChan   chan func()           // 用于在主循环中执行其他操作的channel
+	stopChan   chan struct{}         // 用于停止时间轮的channel
+	stopOnce   sync.Once             // 保证 stopChan 只关闭一次
+	OnExpired  func(task *Task)      // 任务到期时的统一回调
+	codec      Codec                 // 持久化任务使用的编解码器
+	journal    io.Writer             // 操作日志，为 nil 时不记录
+	journalErr error                 // 写入操作日志时出现的错误
+
+	workers      int                         // 执行回调的 worker 数量，为 0 时每个回调启动一个 goroutine
+	queueSize    int                         // 回调队列的长度
+	backpressure Backpressure                // 回调队列已满时的处理策略
+	queue        chan *Task                  // 回调队列
+	inline       bool                        // 是否在时间轮的 goroutine 中执行回调
+	onError      func(task *Task, err error) // 回调 panic 或被丢弃时的处理函数
+	running      sync.WaitGroup              // 已经到期但回调尚未执行完的任务
+	rounding     Rounding                    // 执行时间的取整方式
+}
+
+// Option 时间轮的可选项。
+type Option func(tw *TimeWheel)
+
+// WithClock 设置时间轮使用的时间源，测试中可以使用 clock.Fake 逐个滴答地推进时间轮。
+func WithClock(c clock.Clock) Option {
+	return func(tw *TimeWheel) {
+		tw.clock = c
+	}
+}
+
+// WithLevels 在第0层之上依次叠加多层时间轮，参数为每层的槽位数。
+// 例如 NewTimeWheel(time.Second, 60, cb, WithLevels(60, 24, 30)) 得到秒、分、时、天四层时间轮，
+// 超出最高层范围的任务在最高层按圈数等待。
+func WithLevels(slotNums ...int) Option {
+	return func(tw *TimeWheel) {
+		for _, n := range slotNums {
+			if n > 0 {
+				top := tw.wheels[len(tw.wheels)-1]
+				tw.wheels = append(tw.wheels, newWheel(n, top.unit*int64(len(top.slots))))
+			}
+		}
+	}
+}
+
+func newWheel(slotNum int, unit int64) *wheel {
+	w := &wheel{slots: make([]*list.List, slotNum), unit: unit}
+	// 初始化每个槽位
+	for i := 0; i < slotNum; i++ {
+		w.slots[i] = list.New()
+	}
+	return w
 }
 
 // NewTimeWheel 创建一个新的时间轮。
 // interval: 时间轮的滴答间隔，即指针多久移动一格。
 // slotNum: 时间轮的槽位数量。
 // onExpiredCallback: 一个统一的回调函数，当任何任务到期时都会被调用。
-func NewTimeWheel(interval time.Duration, slotNum int, onExpiredCallback func(task *Task)) (*TimeWheel, error) {
+func NewTimeWheel(interval time.Duration, slotNum int, onExpiredCallback func(task *Task), opt ...Option) (*TimeWheel, error) {
 	if interval <= 0 || slotNum <= 0 {
 		return nil, fmt.Errorf("interval and slotNum must be positive")
 	}
 	tw := &TimeWheel{
 		interval:   interval,
-		slots:      make([]*list.List, slotNum),
-		timer:      make(map[any]int),
-		currentPos: 0,
-		slotNum:    slotNum,
-		taskChan:   make(chan *Task),
+		wheels:     []*wheel{newWheel(slotNum, 1)},
+		timer:      make(map[any]*list.Element),
+		clock:      clock.Real,
+		taskChan:   make(chan addRequest),
 		removeChan: make(chan any),
+		execChan:   make(chan func()),
 		stopChan:   make(chan struct{}),
 		OnExpired:  onExpiredCallback,
+		codec:      GobCodec{},
 	}
-
-	// 初始化每个槽位
-	for i := 0; i < slotNum; i++ {
-		tw.slots[i] = list.New()
+	for i := range opt {
+		opt[i](tw)
 	}
+	tw.start = tw.clock.Now()
 	return tw, nil
 }
 
 // Start 启动时间轮。
 func (tw *TimeWheel) Start() {
-	tw.ticker = time.NewTicker(tw.interval)
+	tw.start = tw.clock.Now().Add(-time.Duration(tw.ticks) * tw.interval)
+	tw.ticker = tw.clock.NewTicker(tw.interval)
+	tw.startWorkers()
 	go tw.run()
 }
 
-// Stop 停止时间轮。
+// Stop 停止时间轮，可以重复调用。未执行的任务会被丢弃，见 StopAndDrain。
 func (tw *TimeWheel) Stop() {
-	close(tw.stopChan)
+	tw.stopOnce.Do(func() {
+		close(tw.stopChan)
+	})
 }
 
 // AddTask 添加一个定时任务。
 // 任务的Key必须是可比较的类型，并且是唯一的。这是一个线程安全的操作。
-func (tw *TimeWheel) AddTask(task *Task) {
-	tw.taskChan <- task
+// 任务或Key为nil时返回 ErrNilKey，时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) AddTask(task *Task) error {
+	return tw.submit(addRequest{task: task})
+}
+
+// submit 将添加任务的请求发送到主循环。
+func (tw *TimeWheel) submit(req addRequest) error {
+	if req.task == nil || req.task.Key == nil {
+		return ErrNilKey
+	}
+	select {
+	case tw.taskChan <- req:
+		return nil
+	case <-tw.stopChan:
+		return ErrStopped
+	}
 }
 
 // RemoveTask 根据任务的Key来移除一个还未执行的定时任务。这是一个线程安全的操作。
-func (tw *TimeWheel) RemoveTask(key any) {
-	tw.removeChan <- key
+// 时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) RemoveTask(key any) error {
+	select {
+	case tw.removeChan <- key:
+		return nil
+	case <-tw.stopChan:
+		return ErrStopped
+	}
+}
+
+// exec 在时间轮的主循环中执行 fn，并等待其完成。时间轮已经停止时返回 ErrStopped。
+func (tw *TimeWheel) exec(fn func()) error {
+	done := make(chan struct{})
+	select {
+	case tw.execChan <- func() {
+		defer close(done)
+		fn()
+	}:
+		<-done
+		return nil
+	case <-tw.stopChan:
+		return ErrStopped
+	}
 }
 
 // run 是时间轮的主循环 Goroutine。
 func (tw *TimeWheel) run() {
+	defer tw.stopWorkers()
 	defer tw.ticker.Stop()
 	for {
+		// 优先响应停止，停止后不再接收新的操作
 		select {
-		case <-tw.ticker.C:
-			tw.tick()
-		case task := <-tw.taskChan:
-			tw.addTask(task)
+		case <-tw.stopChan:
+			return
+		default:
+		}
+		select {
+		case <-tw.ticker.C():
+			tw.advance()
+		case req := <-tw.taskChan:
+			tw.addTaskAt(req.task, req.at)
 		case key := <-tw.removeChan:
 			tw.removeTask(key)
+		case fn := <-tw.execChan:
+			fn()
 		case <-tw.stopChan:
 			return
 		}
@@ -101,25 +234,36 @@ func (tw *TimeWheel) run() {
 
 // tick 是每次指针移动时执行的核心逻辑。
 func (tw *TimeWheel) tick() {
-	// 获取当前槽位的任务列表
-	l := tw.slots[tw.currentPos]
+	// 从高到低，指针进入高层的新槽位时，将其中的任务下沉到低层
+	for level := len(tw.wheels) - 1; level > 0; level-- {
+		w := tw.wheels[level]
+		if tw.ticks%w.unit != 0 {
+			continue
+		}
+		l := w.slots[tw.ticks/w.unit%int64(len(w.slots))]
+		for e := l.Front(); e != nil; {
+			task := e.Value.(*Task)
+			next := e.Next()
+			// 最高层的任务可能还需要再转几圈
+			if task.expire/w.unit == tw.ticks/w.unit {
+				l.Remove(e)
+				tw.place(task)
+			}
+			e = next
+		}
+	}
+
+	// 获取第0层当前槽位的任务列表
+	l := tw.wheels[0].slots[tw.ticks%int64(len(tw.wheels[0].slots))]
 	// 遍历列表
 	for e := l.Front(); e != nil; {
 		task := e.Value.(*Task)
-		if task.circle > 0 {
-			// 如果任务还需要转几圈，则圈数减一，继续等待
-			task.circle--
+		if task.expire > tw.ticks {
+			// 如果任务还需要转几圈，则继续等待
 			e = e.Next()
 			continue
 		}
 
-		// 任务到期，执行回调。使用 goroutine 以防止回调阻塞时间轮。
-		if tw.OnExpired != nil {
-			go tw.OnExpired(task)
-		} else if task.Callback != nil {
-			go task.Callback(task.Data)
-		}
-
 		// 从链表和映射中移除该任务
 		next := e.Next()
 		l.Remove(e)
@@ -127,43 +271,83 @@ func (tw *TimeWheel) tick() {
 			delete(tw.timer, task.Key)
 		}
 		e = next
+
+		if task.Schedule != nil {
+			tw.runPeriodic(task)
+			continue
+		}
+		// 任务到期，执行回调
+		tw.logRemove(task.Key)
+		tw.fire(task)
 	}
 
 	// 移动指针到下一个槽位
-	tw.currentPos = (tw.currentPos + 1) % tw.slotNum
+	tw.ticks++
 }
 
-// addTask 将任务添加到正确的槽位。
+// addTask 将任务添加到正确的槽位，已经存在相同Key的任务时替换原任务。
 func (tw *TimeWheel) addTask(task *Task) {
+	tw.addTaskAt(task, time.Time{})
+}
+
+// addTaskAt 同 addTask，at 不为零值时一次性任务在 at 执行。
+func (tw *TimeWheel) addTaskAt(task *Task, at time.Time) {
 	if task == nil || task.Key == nil {
 		return
 	}
-	// 计算任务应该在多少个滴答之后执行
-	delayTicks := int(task.Delay / tw.interval)
-	// 计算任务需要转动的圈数
-	task.circle = delayTicks / tw.slotNum
-	// 计算任务最终落脚的槽位索引
-	pos := (tw.currentPos + delayTicks) % tw.slotNum
+	replaced := tw.detach(task.Key) != nil
+	if task.Schedule != nil {
+		tw.addPeriodic(task)
+	} else {
+		// 计算任务的执行时间和对应的滴答
+		task.next = at
+		if task.next.IsZero() {
+			task.next = tw.current().Add(task.Delay)
+		}
+		task.expire = tw.tickOf(task.next)
+		tw.place(task)
+	}
+	if _, exists := tw.timer[task.Key]; exists {
+		tw.logAdd(task)
+	} else if replaced {
+		// 新任务不需要执行(如重复任务已经结束)，被替换的任务也要从日志中移除
+		tw.logRemove(task.Key)
+	}
+}
 
-	// 将任务添加到槽位的链表中
-	tw.slots[pos].PushBack(task)
-	// 记录任务Key和槽位索引的映射关系
-	tw.timer[task.Key] = pos
+// place 将任务放入能容纳其到期时间的最低层，并记录任务Key和位置的映射关系。
+// 第 i 层存放与当前滴答同处第 i+1 层的一个槽位内的任务，其余任务放在最高层。
+func (tw *TimeWheel) place(task *Task) {
+	level := len(tw.wheels) - 1
+	for i := 0; i < len(tw.wheels)-1; i++ {
+		unit := tw.wheels[i+1].unit
+		if task.expire/unit == tw.ticks/unit {
+			level = i
+			break
+		}
+	}
+	w := tw.wheels[level]
+	// 计算任务最终落脚的槽位索引
+	task.slot = w.slots[task.expire/w.unit%int64(len(w.slots))]
+	tw.timer[task.Key] = task.slot.PushBack(task)
 }
 
 // removeTask 移除任务。
 func (tw *TimeWheel) removeTask(key any) {
-	pos, ok := tw.timer[key]
-	if !ok {
-		return
+	if tw.detach(key) != nil {
+		tw.logRemove(key)
 	}
-	l := tw.slots[pos]
-	for e := l.Front(); e != nil; e = e.Next() {
-		task := e.Value.(*Task)
-		if task.Key == key {
-			delete(tw.timer, key)
-			l.Remove(e)
-			break
-		}
+}
+
+// detach 将任务从时间轮中取出，任务不存在时返回 nil。
+func (tw *TimeWheel) detach(key any) *Task {
+	e, ok := tw.timer[key]
+	if !ok {
+		return nil
 	}
+	delete(tw.timer, key)
+	task := e.Value.(*Task)
+	task.slot.Remove(e)
+	task.slot = nil
+	return task
 }
@@ -0,0 +1,642 @@
+package timewheel
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NumberMan1/numbox/utils/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectFired 检查第 tick 次滴答到期的任务正好是 keys
+func expectFired(t *testing.T, fired chan any, keys []int, tick int64) {
+	got := make([]int, 0, len(keys))
+	for range keys {
+		select {
+		case key := <-fired:
+			got = append(got, key.(int))
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d: 等待任务到期超时", tick)
+		}
+	}
+	select {
+	case key := <-fired:
+		t.Fatalf("tick %d: 任务 %v 提前到期", tick, key)
+	case <-time.After(time.Millisecond):
+	}
+	sort.Ints(got)
+	assert.Equal(t, keys, got, "tick %d", tick)
+}
+
+func TestHierarchical(t *testing.T) {
+	for _, levels := range [][]int{nil, {4}, {4, 3}} {
+		t.Run(fmt.Sprint(levels), func(t *testing.T) {
+			fired := make(chan any, 100)
+			tw, err := NewTimeWheel(time.Second, 5, func(task *Task) { fired <- task.Key }, WithLevels(levels...))
+			assert.NoError(t, err)
+
+			rnd := rand.New(rand.NewSource(1))
+			const total = 200
+			expect := make(map[int64][]int)
+			// 先推进一段时间，使指针不在起点
+			for i := 0; i < 7; i++ {
+				tw.tick()
+			}
+			for key := 0; key < total; key++ {
+				delay := rnd.Int63n(total)
+				tw.addTask(&Task{Delay: time.Duration(delay) * time.Second, Key: key})
+				// 第 n 次滴答对应的时间为 n+1 秒之后，延迟为 0 的任务在下一次滴答执行
+				tick := max(delay-1, 0)
+				expect[tick] = append(expect[tick], key)
+			}
+			// 移除任务，包括不存在的任务
+			removed := map[int]bool{3: true, 42: true, total: true}
+			for key := range removed {
+				tw.removeTask(key)
+			}
+			for delay := int64(0); delay <= total; delay++ {
+				keys := []int{}
+				for _, key := range expect[delay] {
+					if !removed[key] {
+						keys = append(keys, key)
+					}
+				}
+				sort.Ints(keys)
+				tw.tick()
+				expectFired(t, fired, keys, delay)
+			}
+			assert.Empty(t, tw.timer)
+		})
+	}
+}
+
+func TestTimeWheel(t *testing.T) {
+	fired := make(chan any, 10)
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw, err := NewTimeWheel(10*time.Millisecond, 8, nil, WithLevels(8), WithClock(fake))
+	assert.NoError(t, err)
+	tw.Start()
+	defer tw.Stop()
+
+	callback := func(data any) { fired <- data }
+	tw.AddTask(&Task{Delay: 200 * time.Millisecond, Key: 1, Data: 1, Callback: callback})
+	tw.AddTask(&Task{Delay: 100 * time.Millisecond, Key: 2, Data: 2, Callback: callback})
+	tw.AddTask(&Task{Delay: 30 * time.Millisecond, Key: 3, Data: 3, Callback: callback})
+	tw.RemoveTask(2)
+	for i := int64(1); i <= 21; i++ {
+		fake.Advance(10 * time.Millisecond)
+		switch i {
+		case 3:
+			expectFired(t, fired, []int{3}, i)
+		case 20:
+			expectFired(t, fired, []int{1}, i)
+		default:
+			expectFired(t, fired, []int{}, i)
+		}
+	}
+}
+
+// 10 万个延迟在一天以内的任务，比较单层时间轮与多层时间轮每次滴答的开销
+// BenchmarkTick/single-level          	   10000	    190120 ns/op
+// BenchmarkTick/hierarchical          	   10000	      3182 ns/op
+func BenchmarkTick(b *testing.B) {
+	const total = 100000
+	run := func(b *testing.B, opt ...Option) {
+		tw, _ := NewTimeWheel(time.Second, 60, func(task *Task) {}, opt...)
+		rnd := rand.New(rand.NewSource(1))
+		for key := 0; key < total; key++ {
+			tw.addTask(&Task{Delay: time.Duration(rnd.Int63n(int64(24 * time.Hour))), Key: key})
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tw.tick()
+		}
+	}
+	b.Run("single-level", func(b *testing.B) {
+		run(b)
+	})
+	b.Run("hierarchical", func(b *testing.B) {
+		run(b, WithLevels(60, 24))
+	})
+}
+
+func TestParseCron(t *testing.T) {
+	ast := assert.New(t)
+	loc := time.UTC
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		ast.NoError(err)
+		return tm
+	}
+	cases := []struct {
+		expr, from, next string
+	}{
+		{"0 5 * * *", "2024-01-01 04:59", "2024-01-01 05:00"},
+		{"0 5 * * *", "2024-01-01 05:00", "2024-01-02 05:00"},
+		{"*/15 9-18 * * 1-5", "2024-01-05 18:50", "2024-01-08 09:00"}, // 周五晚上到下周一
+		{"30 0 1 */3 *", "2024-02-10 00:00", "2024-04-01 00:30"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 12 13 * 5", "2024-01-01 00:00", "2024-01-05 12:00"}, // 日和星期满足其一即可
+		{"0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		{"5,10 * * * *", "2024-01-01 00:05", "2024-01-01 00:10"},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expr)
+		ast.NoError(err, c.expr)
+		ast.Equal(at(c.next), schedule.Next(at(c.from)), c.expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := ParseCron(expr)
+		ast.Error(err, expr)
+	}
+	schedule, _ := ParseCron("0 0 30 2 *")
+	ast.True(schedule.Next(at("2024-01-01 00:00")).IsZero())
+}
+
+func TestPeriodic(t *testing.T) {
+	ast := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 回调中记录任务 Key、执行次数和计划时间
+	fired := make(chan string, 100)
+	onExpired := func(task *Task) {
+		fired <- fmt.Sprintf("%v#%d@%v", task.Key, task.Runs(), task.ScheduledAt().Sub(start))
+	}
+	newWheel := func() (*TimeWheel, *clock.Fake) {
+		fake := clock.NewFake(start)
+		tw, err := NewTimeWheel(time.Second, 8, onExpired, WithLevels(8), WithClock(fake))
+		ast.NoError(err)
+		tw.Start()
+		return tw, fake
+	}
+	// expect 推进 d，等待并检查执行的任务
+	expect := func(fake *clock.Fake, d time.Duration, want ...string) {
+		fake.Advance(d)
+		var got []string
+		for range want {
+			select {
+			case r := <-fired:
+				got = append(got, r)
+			case <-time.After(5 * time.Second):
+				t.Fatal("等待任务到期超时")
+			}
+		}
+		sort.Strings(got)
+		ast.Equal(want, got)
+		ast.Len(fired, 0)
+	}
+
+	tw, fake := newWheel()
+	tw.AddTask(&Task{Key: "every3", Schedule: Every(3 * time.Second), MaxRuns: 3})
+	tw.AddTask(&Task{Key: "bounded", Schedule: Every(4 * time.Second),
+		StartAt: start.Add(10 * time.Second), EndAt: start.Add(22 * time.Second)})
+	tw.AddTask(&Task{Key: "canceled", Schedule: Every(5 * time.Second)})
+	expect(fake, 6*time.Second, "canceled#1@5s", "every3#1@3s", "every3#2@6s")
+	tw.RemoveTask("canceled")
+	expect(fake, 30*time.Second, "bounded#1@14s", "bounded#2@18s", "bounded#3@22s", "every3#3@9s")
+	n, err := tw.Len()
+	ast.NoError(err)
+	ast.Zero(n)
+	tw.Stop()
+
+	// 错过的执行：跳过或依次补上
+	tw, fake = newWheel()
+	expect(fake, 10*time.Second)
+	tw.AddTask(&Task{Key: "skip", Schedule: Every(4 * time.Second), StartAt: start})
+	tw.AddTask(&Task{Key: "catchup", Schedule: Every(4 * time.Second), StartAt: start, CatchUp: true, MaxRuns: 4})
+	expect(fake, time.Second, "catchup#1@4s", "catchup#2@8s")
+	expect(fake, 7*time.Second, "catchup#3@12s", "catchup#4@16s", "skip#1@14s", "skip#2@18s")
+	tw.Stop()
+
+	// cron 表达式
+	tw, fake = newWheel()
+	daily, _ := ParseCron("0 5 * * *")
+	tw.AddTask(&Task{Key: "daily", Schedule: daily, MaxRuns: 2})
+	expect(fake, 48*time.Hour, "daily#1@5h0m0s", "daily#2@29h0m0s")
+	tw.Stop()
+}
+
+func TestPersist(t *testing.T) {
+	ast := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type mail struct {
+		To    string
+		Title string
+	}
+	gob.Register(mail{})
+
+	fired := make(chan *Task, 10)
+	onExpired := func(task *Task) { fired <- task }
+	// expect 推进 n 秒，检查执行的任务
+	expect := func(fake *clock.Fake, n int, keys ...string) {
+		fake.Advance(time.Duration(n) * time.Second)
+		var got []string
+		for range keys {
+			select {
+			case task := <-fired:
+				got = append(got, fmt.Sprint(task.Key))
+			case <-time.After(time.Second):
+				t.Fatal("等待任务到期超时")
+			}
+		}
+		sort.Strings(got)
+		ast.Equal(keys, got)
+		ast.Len(fired, 0)
+	}
+
+	fake := clock.NewFake(start)
+	var journal, snapshot, journal2 bytes.Buffer
+	tw, err := NewTimeWheel(time.Second, 8, onExpired, WithLevels(8), WithClock(fake), WithJournal(&journal))
+	ast.NoError(err)
+	tw.Start()
+	tw.AddTask(&Task{Delay: 2 * time.Second, Key: "buff"})
+	tw.AddTask(&Task{Delay: 30 * time.Second, Key: "mail", Data: mail{To: "a", Title: "hi"}})
+	tw.AddTask(&Task{Delay: 40 * time.Second, Key: "removed"})
+	tw.AddTask(&Task{Key: "daily", Schedule: Every(10 * time.Second), MaxRuns: 3})
+	tw.RemoveTask("removed")
+	expect(fake, 3, "buff")
+	ast.NoError(tw.Snapshot(&snapshot, &journal2))
+	expect(fake, 8, "daily")
+	tw.AddTask(&Task{Delay: 5 * time.Second, Key: 42, Data: 1.5})
+	// 被已经结束的重复任务替换，恢复后不应再出现
+	tw.AddTask(&Task{Delay: 50 * time.Second, Key: "replaced"})
+	tw.AddTask(&Task{Key: "replaced", Schedule: Every(time.Second), EndAt: start})
+	tw.exec(func() {}) // 等待任务添加完成
+	tw.Stop()
+
+	// 停机 20 秒后恢复，期间到期的任务在第一次滴答时执行
+	// 从快照和之后的日志恢复，或者从完整的日志恢复，结果相同
+	for _, readers := range [][]io.Reader{
+		{bytes.NewReader(snapshot.Bytes()), bytes.NewReader(journal2.Bytes())},
+		{bytes.NewReader(journal.Bytes()), bytes.NewReader(journal2.Bytes())},
+	} {
+		fake := clock.NewFake(start.Add(31 * time.Second))
+		tw, err := NewTimeWheel(time.Second, 8, onExpired, WithLevels(8), WithClock(fake))
+		ast.NoError(err)
+		tw.Start()
+		ast.NoError(tw.Restore(readers...))
+		fake.Advance(time.Second)
+		got := map[string]*Task{}
+		for i := 0; i < 3; i++ {
+			task := <-fired
+			got[fmt.Sprint(task.Key)] = task
+		}
+		ast.Equal(mail{To: "a", Title: "hi"}, got["mail"].Data)
+		ast.Equal(1.5, got["42"].Data)
+		ast.Equal(2, got["daily"].Runs())
+		expect(fake, 9, "daily")
+		expect(fake, 60)
+		tw.Stop()
+	}
+
+	ast.Error(tw.Restore(strings.NewReader("{\"op\":\"add\"}\nnot json\n")))
+
+	// ParseCron 无法解析的执行计划在写入时报错，恢复时跳过无法解析的记录，其余任务照常恢复
+	fake = clock.NewFake(start)
+	journal.Reset()
+	snapshot.Reset()
+	tw, err = NewTimeWheel(time.Second, 8, onExpired, WithClock(fake), WithJournal(&journal))
+	ast.NoError(err)
+	tw.Start()
+	defer tw.Stop()
+	tw.AddTask(&Task{Key: "weekly", Schedule: weekly{}})
+	tw.AddTask(&Task{Delay: 5 * time.Second, Key: "once"})
+	ast.Error(tw.Snapshot(&snapshot, nil))
+	ast.NotContains(snapshot.String(), "weekly")
+	ast.NotContains(journal.String(), "weekly")
+
+	restored, err := NewTimeWheel(time.Second, 8, onExpired, WithClock(fake))
+	ast.NoError(err)
+	restored.Start()
+	defer restored.Stop()
+	bad := `{"op":"add","key":"` + base64.StdEncoding.EncodeToString(mustGob(t, "bad")) + `","schedule":"weekly"}` + "\nnot json\n"
+	ast.Error(restored.Restore(strings.NewReader(bad), bytes.NewReader(snapshot.Bytes())))
+	n, err := restored.Len()
+	ast.NoError(err)
+	ast.Equal(1, n)
+	exists, _ := restored.Exists("once")
+	ast.True(exists)
+}
+
+// weekly 有 String 方法但无法由 ParseCron 解析的执行计划
+type weekly struct{}
+
+func (weekly) Next(t time.Time) time.Time { return t.Add(7 * 24 * time.Hour) }
+func (weekly) String() string             { return "weekly" }
+
+// mustGob 使用 GobCodec 编码
+func mustGob(t *testing.T, v any) []byte {
+	data, err := GobCodec{}.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCallbacks(t *testing.T) {
+	ast := assert.New(t)
+	errs := make(chan error, 10)
+	onError := func(task *Task, err error) { errs <- err }
+
+	t.Run("panic", func(t *testing.T) {
+		tw, _ := NewTimeWheel(time.Second, 8, nil, WithErrorHandler(onError))
+		tw.addTask(&Task{Key: 1, Callback: func(any) { panic("boom") }})
+		tw.tick()
+		err := <-errs
+		ast.ErrorContains(err, "boom")
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		var got []any
+		tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) {
+			got = append(got, task.Key)
+			if task.Key == 2 {
+				panic("boom")
+			}
+		}, WithInlineCallbacks(), WithErrorHandler(onError))
+		for key := 1; key <= 3; key++ {
+			tw.addTask(&Task{Key: key})
+		}
+		tw.tick()
+		ast.Equal([]any{1, 2, 3}, got)
+		ast.ErrorContains(<-errs, "boom")
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		started := make(chan any)
+		gate := make(chan struct{})
+		var inline []any
+		tw, _ := NewTimeWheel(time.Second, 8, nil, WithWorkerPool(2, 1, BackpressureDrop), WithErrorHandler(onError))
+		tw.startWorkers()
+		for key := 1; key <= 4; key++ {
+			tw.addTask(&Task{Key: key, Data: key, Callback: func(data any) {
+				started <- data
+				<-gate
+			}})
+			tw.tick()
+			if key <= 2 {
+				ast.Equal(key, <-started)
+			}
+		}
+		// 前两个回调正在执行，第三个在队列中，第四个被丢弃
+		ast.ErrorIs(<-errs, ErrQueueFull)
+
+		// 队列已满时在调用 tick 的 goroutine 中执行
+		tw.backpressure = BackpressureCallerRuns
+		tw.addTask(&Task{Key: 5, Data: 5, Callback: func(data any) {
+			inline = append(inline, data)
+		}})
+		tw.tick()
+		ast.Equal([]any{5}, inline)
+		close(gate)
+		ast.Equal(3, <-started)
+		tw.stopWorkers()
+		ast.Len(errs, 0)
+	})
+}
+
+func TestLifecycle(t *testing.T) {
+	ast := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fake := clock.NewFake(start)
+	tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) {}, WithClock(fake))
+	tw.Start()
+	ast.ErrorIs(tw.AddTask(nil), ErrNilKey)
+	ast.ErrorIs(tw.AddTask(&Task{}), ErrNilKey)
+	ast.NoError(tw.AddTask(&Task{Key: 1, Delay: time.Second}))
+	ast.NoError(tw.RemoveTask(1))
+	tw.Stop()
+	tw.Stop()
+	ast.ErrorIs(tw.AddTask(&Task{Key: 1}), ErrStopped)
+	ast.ErrorIs(tw.AddTaskAt(&Task{Key: 1}, start), ErrStopped)
+	ast.ErrorIs(tw.RemoveTask(1), ErrStopped)
+	ast.ErrorIs(tw.Snapshot(io.Discard, nil), ErrStopped)
+	_, err := tw.StopAndDrain(context.Background(), DrainReturn)
+	ast.ErrorIs(err, ErrStopped)
+
+	t.Run("return", func(t *testing.T) {
+		fake := clock.NewFake(start)
+		tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) {}, WithLevels(8), WithClock(fake))
+		tw.Start()
+		tw.AddTask(&Task{Key: "late", Delay: 20 * time.Second})
+		tw.AddTask(&Task{Key: "early", Delay: 5 * time.Second})
+		tw.AddTask(&Task{Key: "periodic", Schedule: Every(10 * time.Second)})
+		fake.Advance(3 * time.Second)
+		pending, err := tw.StopAndDrain(context.Background(), DrainReturn)
+		ast.NoError(err)
+		var got []string
+		for _, task := range pending {
+			got = append(got, fmt.Sprintf("%v:%v", task.Key, task.Delay))
+		}
+		ast.Equal([]string{"early:2s", "periodic:0s", "late:17s"}, got)
+		ast.ErrorIs(tw.AddTask(&Task{Key: 1}), ErrStopped)
+	})
+
+	t.Run("fire", func(t *testing.T) {
+		var fired []any
+		var mu sync.Mutex
+		tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			fired = append(fired, task.Key)
+			mu.Unlock()
+		}, WithClock(clock.NewFake(start)), WithWorkerPool(1, 10, BackpressureBlock))
+		tw.Start()
+		for key := 3; key > 0; key-- {
+			tw.AddTask(&Task{Key: key, Delay: time.Duration(key) * time.Hour})
+		}
+		pending, err := tw.StopAndDrain(context.Background(), DrainFire)
+		ast.NoError(err)
+		ast.Empty(pending)
+		ast.Equal([]any{1, 2, 3}, fired)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		gate := make(chan struct{})
+		defer close(gate)
+		tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) { <-gate }, WithClock(clock.NewFake(start)))
+		tw.Start()
+		tw.AddTask(&Task{Key: 1, Delay: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := tw.StopAndDrain(ctx, DrainFire)
+		ast.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
+
+func TestQuery(t *testing.T) {
+	ast := assert.New(t)
+	fired := make(chan any, 10)
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) { fired <- task.Key }, WithLevels(8), WithClock(fake))
+	tw.Start()
+	defer tw.Stop()
+	length := func() int {
+		n, err := tw.Len()
+		ast.NoError(err)
+		return n
+	}
+	exists := func(key any) bool {
+		ok, err := tw.Exists(key)
+		ast.NoError(err)
+		return ok
+	}
+
+	tw.AddTask(&Task{Key: "a", Delay: 10 * time.Second})
+	tw.AddTask(&Task{Key: "b", Delay: 20 * time.Second})
+	tw.AddTask(&Task{Key: "c", Delay: 30 * time.Second, Data: "old"})
+	// 相同 Key 的任务替换原任务
+	tw.AddTask(&Task{Key: "c", Delay: 5 * time.Second, Data: "new"})
+	ast.Equal(3, length())
+	ast.True(exists("a"))
+	ast.False(exists("d"))
+
+	fake.Advance(2 * time.Second)
+	remaining, err := tw.Remaining("a")
+	ast.NoError(err)
+	ast.Equal(8*time.Second, remaining)
+	_, err = tw.Remaining("d")
+	ast.ErrorIs(err, ErrTaskNotFound)
+
+	ast.NoError(tw.Reschedule("a", 30*time.Second))
+	ast.ErrorIs(tw.Reschedule("d", time.Second), ErrTaskNotFound)
+	var got []string
+	for task, remaining := range tw.Tasks() {
+		got = append(got, fmt.Sprintf("%v:%v:%v", task.Key, task.Data, remaining))
+	}
+	ast.Equal([]string{"c:new:3s", "b:<nil>:18s", "a:<nil>:30s"}, got)
+	for range tw.Tasks() {
+		break
+	}
+
+	fake.Advance(4 * time.Second)
+	ast.Equal("c", <-fired)
+	ast.Equal(2, length())
+	fake.Advance(30 * time.Second)
+	ast.ElementsMatch([]any{"a", "b"}, []any{<-fired, <-fired})
+	ast.Equal(0, length())
+	ast.Len(fired, 0)
+
+	tw.Stop()
+	_, err = tw.Exists("a")
+	ast.ErrorIs(err, ErrStopped)
+	_, err = tw.Len()
+	ast.ErrorIs(err, ErrStopped)
+	_, err = tw.Remaining("a")
+	ast.ErrorIs(err, ErrStopped)
+	ast.ErrorIs(tw.Reschedule("a", time.Second), ErrStopped)
+}
+
+func TestTiming(t *testing.T) {
+	ast := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		rounding Rounding
+		counts   []int // 每次滴答之后累计执行的任务数
+		expect   []string
+	}{
+		{RoundUp, []int{2, 2, 3, 4}, []string{"1s:past", "1s:short", "3s:at", "4s:delay"}},
+		{RoundNearest, []int{2, 3, 3, 4}, []string{"1s:past", "1s:short", "2s:at", "4s:delay"}},
+	} {
+		fired := make(chan *Task, 10)
+		fake := clock.NewFake(start)
+		tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) { fired <- task }, WithClock(fake), WithRounding(c.rounding))
+		tw.Start()
+		// 延迟小于滴答间隔的任务不会错过当前槽位
+		tw.AddTask(&Task{Key: "short", Delay: 100 * time.Millisecond})
+		tw.AddTask(&Task{Key: "delay", Delay: 3600 * time.Millisecond})
+		ast.ErrorIs(tw.AddTaskAt(&Task{}, start), ErrNilKey)
+		tw.AddTaskAt(&Task{Key: "at", Delay: time.Hour}, start.Add(2400*time.Millisecond))
+		tw.AddTaskAt(&Task{Key: "past"}, start.Add(-time.Hour))
+		var got []string
+		for i := 0; i < 4; i++ {
+			fake.Advance(time.Second)
+			for len(got) < c.counts[i] {
+				task := <-fired
+				got = append(got, fmt.Sprintf("%v:%v", fake.Now().Sub(start), task.Key))
+			}
+		}
+		sort.Strings(got)
+		ast.Equal(c.expect, got, c.rounding)
+		tw.Stop()
+	}
+
+	// ticker 丢弃的滴答根据时钟补上
+	fired := make(chan *Task, 10)
+	fake := clock.NewFake(start)
+	tw, _ := NewTimeWheel(time.Second, 8, func(task *Task) { fired <- task }, WithClock(fake))
+	for i := 1; i <= 6; i++ {
+		tw.addTask(&Task{Key: i, Delay: time.Duration(i) * time.Second})
+	}
+	fake.Advance(4500 * time.Millisecond)
+	tw.advance()
+	ast.EqualValues(4, tw.ticks)
+	var keys []int
+	for i := 0; i < 4; i++ {
+		task := <-fired
+		keys = append(keys, task.Key.(int))
+		ast.Equal(start.Add(time.Duration(task.Key.(int))*time.Second), task.ScheduledAt())
+	}
+	sort.Ints(keys)
+	ast.Equal([]int{1, 2, 3, 4}, keys)
+}
+
+// 100 万个任务分布在 60 个槽位中，每次操作移除一个任务后重新添加
+// scan 为遍历槽位链表查找任务的基准，element 通过 Key 直接找到链表元素:
+// BenchmarkRemoveTask/scan    	    2000	   1493707 ns/op
+// BenchmarkRemoveTask/element 	  536379	      2167 ns/op
+func BenchmarkRemoveTask(b *testing.B) {
+	const total = 1000000
+	for _, c := range []struct {
+		name   string
+		remove func(tw *TimeWheel, key any)
+	}{
+		{"scan", scanRemove},
+		{"element", (*TimeWheel).removeTask},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			tw, _ := NewTimeWheel(time.Second, 60, func(task *Task) {}, WithClock(clock.NewFake(time.Now())))
+			rnd := rand.New(rand.NewSource(1))
+			tasks := make([]*Task, total)
+			for key := range tasks {
+				tasks[key] = &Task{Delay: time.Duration(rnd.Int63n(int64(24 * time.Hour))), Key: key}
+				tw.addTask(tasks[key])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				task := tasks[rnd.Intn(total)]
+				c.remove(tw, task.Key)
+				tw.addTask(task)
+			}
+		})
+	}
+}
+
+// scanRemove 只记录任务所在的槽位，移除时遍历槽位链表查找任务，作为 removeTask 的对比基准。
+func scanRemove(tw *TimeWheel, key any) {
+	e, ok := tw.timer[key]
+	if !ok {
+		return
+	}
+	l := e.Value.(*Task).slot
+	for e := l.Front(); e != nil; e = e.Next() {
+		if task := e.Value.(*Task); task.Key == key {
+			delete(tw.timer, key)
+			l.Remove(e)
+			task.slot = nil
+			tw.logRemove(key)
+			return
+		}
+	}
+}
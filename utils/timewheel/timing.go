@@ -0,0 +1,69 @@
+package timewheel
+
+import (
+	"time"
+)
+
+// Rounding 任务的执行时间与滴答不对齐时的取整方式。
+type Rounding int
+
+const (
+	RoundUp      Rounding = iota // 在执行时间之后的第一个滴答执行，任务不会提前执行
+	RoundNearest                 // 在离执行时间最近的滴答执行，最多提前或推迟半个滴答
+)
+
+// WithRounding 设置任务执行时间的取整方式，默认为 RoundUp。
+func WithRounding(r Rounding) Option {
+	return func(tw *TimeWheel) {
+		tw.rounding = r
+	}
+}
+
+// AddTaskAt 添加一个在 at 执行的任务，忽略任务的 Delay，已经过去的时间在下一次滴答时执行。
+// 重复任务的开始时间请使用 StartAt，对重复任务 at 不起作用。其他同 AddTask。
+func (tw *TimeWheel) AddTaskAt(task *Task, at time.Time) error {
+	return tw.submit(addRequest{task: task, at: at})
+}
+
+// now 返回时间轮当前的时间，即最近一次滴答对应的时间。
+func (tw *TimeWheel) now() time.Time {
+	return tw.start.Add(time.Duration(tw.ticks) * tw.interval)
+}
+
+// current 返回计算延迟时使用的当前时间，取时钟时间和时间轮时间中较晚的一个。
+func (tw *TimeWheel) current() time.Time {
+	now, wheel := tw.clock.Now(), tw.now()
+	if now.Before(wheel) {
+		return wheel
+	}
+	return now
+}
+
+// timeOf 返回第 tick 次滴答对应的时间。
+func (tw *TimeWheel) timeOf(tick int64) time.Time {
+	return tw.start.Add(time.Duration(tick+1) * tw.interval)
+}
+
+// tickOf 按取整方式返回执行时间 t 对应的滴答序号，已经过去的时间返回下一个要处理的滴答。
+func (tw *TimeWheel) tickOf(t time.Time) int64 {
+	d := t.Sub(tw.start)
+	if tw.rounding == RoundNearest {
+		d += tw.interval / 2
+	} else {
+		d += tw.interval - 1
+	}
+	tick := int64(d/tw.interval) - 1
+	if tick < tw.ticks {
+		return tw.ticks
+	}
+	return tick
+}
+
+// advance 处理到时钟当前时间为止的所有滴答。
+// ticker 在时间轮繁忙时会丢弃滴答，这里根据时钟补上丢失的滴答，保证任务的执行时间不随之推迟。
+func (tw *TimeWheel) advance() {
+	target := int64(tw.clock.Now().Sub(tw.start) / tw.interval)
+	for tw.ticks < target {
+		tw.tick()
+	}
+}